@@ -0,0 +1,45 @@
+package websockethandler
+
+import "time"
+
+// Entry of the access log, one per call
+type AccessLogEntry struct {
+	Time  time.Time
+	Event string
+	// Correlation id of the request, generated if the inbound payload has none
+	RequestID string
+	Status    string
+	Client    interface{}
+	Duration  time.Duration
+	Err       error
+}
+
+// Setting the access logger called after every CallFunc and pipeline call
+// with any outcome, separately from the operational logging.
+// The client id is derived from Client by the access logger
+func (h *wsHandler) SetAccessLogger(f func(AccessLogEntry)) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.accessLogger = f
+	}
+	return h
+}
+
+func (h *wsHandler) accessLog(start time.Time, meta WsFunc, data WsFuncData, status string, err error) {
+	h.mutex.RLock()
+	accessLogger := h.accessLogger
+	h.mutex.RUnlock()
+	if accessLogger == nil {
+		return
+	}
+	accessLogger(AccessLogEntry{
+		Time:      start,
+		Event:     meta.Event,
+		RequestID: data.Payload.RequestID,
+		Status:    status,
+		Client:    data.Client,
+		Duration:  h.now().Sub(start),
+		Err:       err,
+	})
+}
@@ -0,0 +1,48 @@
+package websockethandler
+
+import (
+	"context"
+	"sync"
+)
+
+type accumulatorCtx struct{}
+
+// Shared accumulator of the values of T added by the stages of a pipeline.
+// Its lifetime is exactly one pipeline invocation of CallPipelineAccumulate:
+// it is created before the first stage and its values are returned after the last one.
+// A pipeline called by a stage with its ctx adds to the same accumulator
+type Accumulator[T any] struct {
+	mutex  sync.Mutex
+	values []T
+}
+
+// Appending a value to the accumulator
+func (a *Accumulator[T]) Add(v T) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.values = append(a.values, v)
+}
+
+// Copy of the accumulated values
+func (a *Accumulator[T]) Values() []T {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	values := make([]T, len(a.values))
+	copy(values, a.values)
+	return values
+}
+
+// Returns the accumulator of the running pipeline.
+// False is returned if the pipeline was not called by CallPipelineAccumulate of T
+func AccumulatorFromContext[T any](ctx context.Context) (*Accumulator[T], bool) {
+	acc, ok := ctx.Value(accumulatorCtx{}).(*Accumulator[T])
+	return acc, ok
+}
+
+// Calling an event in pipeline mode like CallPipelineFunc and returning the values
+// added by the stages to the accumulator of T from AccumulatorFromContext
+func CallPipelineAccumulate[T any](h WsHandler, ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload) ([]T, error) {
+	acc := &Accumulator[T]{}
+	err := h.CallPipelineFunc(context.WithValue(ctx, accumulatorCtx{}, acc), meta, data, ch)
+	return acc.Values(), err
+}
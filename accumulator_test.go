@@ -0,0 +1,49 @@
+package websockethandler
+
+import (
+	"context"
+	"testing"
+)
+
+// Stage adding its name to the accumulator of strings
+func accumulating(name string) HandlerFunc {
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		if acc, ok := AccumulatorFromContext[string](ctx); ok {
+			acc.Add(name)
+		}
+		return data, nil
+	}
+}
+
+func TestCallPipelineAccumulate(t *testing.T) {
+	h := newTestHandler(t)
+	first := accumulating("a")
+	h.Handle(WsFunc{Event: "first"}, first)
+	h.Handle(WsFunc{Event: "second"}, accumulating("b"), first)
+
+	ch := make(chan MessagePayload, 4)
+	values, err := CallPipelineAccumulate[string](h, context.Background(), WsFunc{Event: "first"}, WsFuncData{Payload: MessagePayload{Event: "first"}}, ch)
+	if err != nil {
+		t.Fatalf("call pipeline: %v", err)
+	}
+	if len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Fatalf("values = %v, want [a b]", values)
+	}
+
+	// Every invocation has its own accumulator
+	values, _ = CallPipelineAccumulate[string](h, context.Background(), WsFunc{Event: "first"}, WsFuncData{Payload: MessagePayload{Event: "first"}}, make(chan MessagePayload, 4))
+	if len(values) != 2 {
+		t.Fatalf("values of the second call = %v, want two", values)
+	}
+}
+
+func TestAccumulatorOfAnotherType(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "first"}, accumulating("a"))
+	values, err := CallPipelineAccumulate[int](h, context.Background(), WsFunc{Event: "first"}, WsFuncData{Payload: MessagePayload{Event: "first"}}, make(chan MessagePayload, 4))
+	if err != nil || len(values) != 0 {
+		t.Fatalf("values = %v, %v, want none", values, err)
+	}
+	if _, ok := AccumulatorFromContext[string](context.Background()); ok {
+		t.Fatal("an accumulator outside of a pipeline")
+	}
+}
@@ -0,0 +1,53 @@
+package websockethandler
+
+import "fmt"
+
+// Routing the old event name to the handler of the new one.
+// Aliases are resolved once, after the event normalizer and before any lookup of the handler,
+// the payload is passed to the handler with the event sent by the client
+func (h *wsHandler) AddEventAlias(oldEvent, newEvent string) WsHandler {
+	if h.err == nil {
+		if oldEvent == newEvent {
+			h.err = fmt.Errorf("event alias refers to itself:%s:%s", oldEvent, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.aliases[h.normalizeEvent(oldEvent)] = h.normalizeEvent(newEvent)
+	}
+	return h
+}
+
+// Normalization, alias resolution and the status wildcard of the incoming meta before the lookup
+func (h *wsHandler) resolveAlias(meta WsFunc) WsFunc {
+	meta = h.normalize(meta)
+	if newEvent, ok := h.aliases[meta.Event]; ok {
+		h.log(
+			warnLevel,
+			fmt.Errorf("deprecated event alias:%s:%s:%s", meta.Event, newEvent, getFunctionName()),
+		)
+		meta.Event = newEvent
+	}
+	return h.matchStatus(meta)
+}
+
+// The same as resolveAlias without logging
+func (h *wsHandler) lookupAlias(meta WsFunc) WsFunc {
+	meta = h.normalize(meta)
+	if newEvent, ok := h.aliases[meta.Event]; ok {
+		meta.Event = newEvent
+	}
+	return h.matchStatus(meta)
+}
+
+// The exact registration wins, otherwise the registration of the event
+// with the empty Status matches any Status
+func (h *wsHandler) matchStatus(meta WsFunc) WsFunc {
+	if meta.Status == "" || h.isRegistered(meta) {
+		return meta
+	}
+	if wildcard := (WsFunc{Event: meta.Event}); h.isRegistered(wildcard) {
+		return wildcard
+	}
+	return meta
+}
@@ -0,0 +1,41 @@
+package websockethandler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExactStatusWinsOverTheWildcard(t *testing.T) {
+	h := newTestHandler(t).
+		Handle(WsFunc{Event: "order"}, stage("any")).
+		Handle(WsFunc{Event: "order", Status: "paid"}, stage("paid"))
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "order", Status: "paid"}, WsFuncData{Payload: MessagePayload{Event: "order"}})
+	if err != nil || out.Payload.Data != "paid" {
+		t.Fatalf("call order#paid = %v, %v, want Data paid", out.Payload.Data, err)
+	}
+}
+
+func TestEmptyStatusMatchesAnyStatus(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "order"}, stage("any"))
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "order", Status: "shipped"}, WsFuncData{Payload: MessagePayload{Event: "order"}})
+	if err != nil || out.Payload.Data != "any" {
+		t.Fatalf("call order#shipped = %v, %v, want Data any", out.Payload.Data, err)
+	}
+	if _, err := h.CallFunc(context.Background(), WsFunc{Event: "refund", Status: "shipped"}, WsFuncData{Payload: MessagePayload{Event: "refund"}}); err == nil {
+		t.Fatal("the wildcard matched another event")
+	}
+}
+
+func TestEventAliasRoutesToTheNewEvent(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "v2"}, stage("new")).AddEventAlias("v1", "v2")
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "v1"}, WsFuncData{Payload: MessagePayload{Event: "v1"}})
+	if err != nil || out.Payload.Data != "new" {
+		t.Fatalf("call v1 = %v, %v, want Data new", out.Payload.Data, err)
+	}
+	if h.AddEventAlias("v3", "v3"); h.GetError() == nil {
+		t.Fatal("an alias referring to itself was accepted")
+	}
+}
@@ -0,0 +1,17 @@
+package websockethandler
+
+// Attachments for the next pipeline stage: the input ones
+// overridden by the ones of the stage output
+func mergeAttachments(in, out map[string][]byte) map[string][]byte {
+	if len(out) == 0 {
+		return in
+	}
+	merged := make(map[string][]byte, len(in)+len(out))
+	for k, v := range in {
+		merged[k] = v
+	}
+	for k, v := range out {
+		merged[k] = v
+	}
+	return merged
+}
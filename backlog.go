@@ -0,0 +1,118 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Behavior of the pipeline when the reader falls behind
+type BacklogPolicy uint8
+
+const (
+	// The next stage waits until the backlog is below the limit
+	BacklogPause BacklogPolicy = iota
+	// The payload is dropped and counted in DroppedPayloads
+	BacklogDrop
+)
+
+const backlogPollInterval = time.Millisecond
+
+// Setting the limit of unread payloads in the pipeline channel, 0 disables it.
+// The backlog is the number of payloads buffered in the channel
+func (h *wsHandler) SetPipelineBacklogLimit(n int) WsHandler {
+	if h.err == nil {
+		if n < 0 {
+			h.err = fmt.Errorf("not a valid backlog limit:%d:%s", n, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.backlogLimit = n
+	}
+	return h
+}
+
+// Setting the behavior on reaching the backlog limit, pause by default
+func (h *wsHandler) SetPipelineBacklogPolicy(policy BacklogPolicy) WsHandler {
+	if h.err == nil {
+		if policy > BacklogDrop {
+			h.err = fmt.Errorf("not a valid backlog policy:%d:%s", policy, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.backlogPolicy = policy
+	}
+	return h
+}
+
+// Number of payloads dropped because of the backlog limit or of the done context
+func (h *wsHandler) DroppedPayloads() uint64 {
+	return atomic.LoadUint64(&h.droppedPayloads)
+}
+
+// Highest number of unread payloads seen in a pipeline channel after a send,
+// a value close to the backlog limit tells that the reader falls behind
+func (h *wsHandler) PipelineBacklogPeak() int {
+	return int(atomic.LoadInt64(&h.backlogPeak))
+}
+
+// Raising the backlog peak to the unread payloads of the channel
+func (h *wsHandler) observeBacklog(ch chan MessagePayload) {
+	n := int64(len(ch))
+	for {
+		peak := atomic.LoadInt64(&h.backlogPeak)
+		if n <= peak || atomic.CompareAndSwapInt64(&h.backlogPeak, peak, n) {
+			return
+		}
+	}
+}
+
+// Sending the stage output with respect to the backlog limit.
+// While paused or while the channel is full, the payload is dropped if ctx is done.
+// Called without the lock, the limit is a part of the configuration of the call
+func (h *wsHandler) send(cfg callConfig, ctx context.Context, ch chan MessagePayload, payload MessagePayload) {
+	if cfg.backlogLimit > 0 && len(ch) >= cfg.backlogLimit {
+		if cfg.backlogPolicy == BacklogDrop || !waitBacklog(ctx, ch, cfg.backlogLimit) {
+			atomic.AddUint64(&h.droppedPayloads, 1)
+			h.log(
+				warnLevel,
+				fmt.Errorf("pipeline backlog limit reached, payload dropped:%d:%s", len(ch), getFunctionName()),
+				payload,
+			)
+			return
+		}
+	}
+	select {
+	case ch <- payload:
+		h.observeBacklog(ch)
+		return
+	default:
+	}
+	select {
+	case ch <- payload:
+		h.observeBacklog(ch)
+	case <-ctx.Done():
+		atomic.AddUint64(&h.droppedPayloads, 1)
+		h.log(
+			warnLevel,
+			fmt.Errorf("context is done, payload dropped:%s", getFunctionName()),
+			payload,
+		)
+	}
+}
+
+func waitBacklog(ctx context.Context, ch chan MessagePayload, limit int) bool {
+	ticker := time.NewTicker(backlogPollInterval)
+	defer ticker.Stop()
+	for len(ch) >= limit {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
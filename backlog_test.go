@@ -0,0 +1,61 @@
+package websockethandler
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestBacklogSettersRaceWithPipelines(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "root"}, stage("a"))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h.CallPipelineFuncSync(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}})
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		h.SetPipelineBacklogLimit(j % 3)
+		h.SetPipelineBacklogPolicy(BacklogPolicy(j % 2))
+	}
+	wg.Wait()
+	if err := h.GetError(); err != nil {
+		t.Fatalf("setters: %v", err)
+	}
+}
+
+func TestPipelineBacklogPeakCountsTheUnreadPayloads(t *testing.T) {
+	root, next, last := stage("a"), stage("b"), stage("c")
+	h := newTestHandler(t).
+		Handle(WsFunc{Event: "root"}, root).
+		Handle(WsFunc{Event: "next"}, next, root).
+		Handle(WsFunc{Event: "last"}, last, next)
+
+	ch := make(chan MessagePayload, 4)
+	if err := h.CallPipelineFunc(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}, ch); err != nil {
+		t.Fatalf("call pipeline: %v", err)
+	}
+	if peak := h.PipelineBacklogPeak(); peak != 3 {
+		t.Fatalf("backlog peak = %d, want the 3 unread payloads", peak)
+	}
+	if stats := h.Stats(); stats.PipelineBacklogPeak != 3 {
+		t.Fatalf("stats = %+v, want the backlog peak 3", stats)
+	}
+}
+
+func TestPipelineBacklogLimitDropsOverTheLimit(t *testing.T) {
+	root, next := stage("a"), stage("b")
+	h := newTestHandler(t).SetPipelineBacklogLimit(1).SetPipelineBacklogPolicy(BacklogDrop).
+		Handle(WsFunc{Event: "root"}, root).
+		Handle(WsFunc{Event: "next"}, next, root)
+
+	ch := make(chan MessagePayload, 4)
+	h.CallPipelineFunc(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}, ch)
+	if len(ch) != 1 || h.DroppedPayloads() != 1 || h.PipelineBacklogPeak() != 1 {
+		t.Fatalf("unread = %d, dropped = %d, peak = %d, want 1, 1, 1", len(ch), h.DroppedPayloads(), h.PipelineBacklogPeak())
+	}
+}
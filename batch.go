@@ -0,0 +1,50 @@
+package websockethandler
+
+import (
+	"context"
+	"time"
+)
+
+// Calling an event like CallStreaming with the outputs produced within
+// each flush interval coalesced into one batch.
+// The last batch is sent when the call is completed, including cancellation
+// of ctx, then the channel is closed. The reader must drain it.
+// A non-positive flush sends every output as a separate batch
+func (h *wsHandler) CallPipelineBatched(ctx context.Context, meta WsFunc, data WsFuncData, flush time.Duration) <-chan []MessagePayload {
+	out := make(chan []MessagePayload, 1)
+	// Only forwarding the outputs, so no slot of the goroutine budget is taken
+	release := h.counted()
+	in := h.CallStreaming(ctx, meta, data)
+	go func() {
+		defer close(out)
+		defer release()
+		if flush <= 0 {
+			for p := range in {
+				out <- []MessagePayload{p}
+			}
+			return
+		}
+
+		ticker := time.NewTicker(flush)
+		defer ticker.Stop()
+		var batch []MessagePayload
+		for {
+			select {
+			case p, ok := <-in:
+				if !ok {
+					if len(batch) > 0 {
+						out <- batch
+					}
+					return
+				}
+				batch = append(batch, p)
+			case <-ticker.C:
+				if len(batch) > 0 {
+					out <- batch
+					batch = nil
+				}
+			}
+		}
+	}()
+	return out
+}
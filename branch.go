@@ -0,0 +1,49 @@
+package websockethandler
+
+import "fmt"
+
+// Choice of the children run after the pipeline stage
+type BranchStrategy uint8
+
+const (
+	// All children are run in the order of registration
+	BranchAll BranchStrategy = iota
+	// Only the children whose Status equals the Status of the stage output are run,
+	// or, if there are none, the children whose Event equals the output Event.
+	// A single child is always run
+	BranchMatch
+)
+
+// Setting the choice of the children of pipeline stages, all children by default
+func (h *wsHandler) SetBranchStrategy(strategy BranchStrategy) WsHandler {
+	if h.err == nil {
+		if strategy > BranchMatch {
+			h.err = fmt.Errorf("not a valid branch strategy:%d:%s", strategy, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.branchStrategy = strategy
+	}
+	return h
+}
+
+// Children of the node to run after the stage has produced the output
+func (h *wsHandler) selectChildren(node *wsHandlerTree, out MessagePayload) []*wsHandlerTree {
+	if h.branchStrategy != BranchMatch || len(node.children) < 2 {
+		return node.children
+	}
+	var byStatus, byEvent []*wsHandlerTree
+	for _, child := range node.children {
+		if child.meta.Status == out.Status {
+			byStatus = append(byStatus, child)
+		}
+		if child.meta.Event == out.Event {
+			byEvent = append(byEvent, child)
+		}
+	}
+	if len(byStatus) > 0 {
+		return byStatus
+	}
+	return byEvent
+}
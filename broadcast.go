@@ -0,0 +1,111 @@
+package websockethandler
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Channels of the connected clients receiving broadcast payloads
+type clients struct {
+	mutex   sync.RWMutex
+	chans   map[string]chan MessagePayload
+	timeout time.Duration
+	dropped uint64
+}
+
+// Registering the channel of the connected client for broadcast payloads,
+// the channel of an already registered id is replaced.
+// The client must be unregistered before its channel is closed
+func (h *wsHandler) RegisterClient(id string, ch chan MessagePayload) WsHandler {
+	if h.err == nil {
+		if ch == nil {
+			h.err = fmt.Errorf("not a valid client channel:%s:%s", id, getFunctionName())
+			return h
+		}
+		h.clients.mutex.Lock()
+		defer h.clients.mutex.Unlock()
+		h.clients.chans[id] = ch
+	}
+	return h
+}
+
+// Removing the client from the broadcast, unknown id does nothing
+func (h *wsHandler) UnregisterClient(id string) WsHandler {
+	h.clients.mutex.Lock()
+	defer h.clients.mutex.Unlock()
+	delete(h.clients.chans, id)
+	return h
+}
+
+// Setting the time the broadcast waits for slow clients, shared by all clients
+// of one broadcast. By default the send is non-blocking and the payload is dropped
+// for the client whose channel is full. Drops are counted in Stats
+func (h *wsHandler) SetBroadcastTimeout(d time.Duration) WsHandler {
+	if h.err == nil {
+		if d < 0 {
+			h.err = fmt.Errorf("not a valid broadcast timeout:%s:%s", d, getFunctionName())
+			return h
+		}
+		h.clients.mutex.Lock()
+		defer h.clients.mutex.Unlock()
+		h.clients.timeout = d
+	}
+	return h
+}
+
+// Sending the payload to all registered clients.
+// The payload returned by CallFunc keeps the Broadcast flag,
+// so that the transport does not deliver it to the sender once more
+func (h *wsHandler) broadcast(payload MessagePayload) {
+	h.clients.mutex.RLock()
+	chans := make(map[string]chan MessagePayload, len(h.clients.chans))
+	for id, ch := range h.clients.chans {
+		chans[id] = ch
+	}
+	timeout := h.clients.timeout
+	h.clients.mutex.RUnlock()
+
+	// Closed when the deadline has passed, so that it stays readable for all clients
+	var expired chan struct{}
+	if timeout > 0 {
+		expired = make(chan struct{})
+		timer := time.AfterFunc(timeout, func() { close(expired) })
+		defer timer.Stop()
+	}
+	for id, ch := range chans {
+		if !h.sendClient(id, ch, payload, expired) {
+			atomic.AddUint64(&h.clients.dropped, 1)
+		}
+	}
+}
+
+// Sending the payload to one client until the broadcast deadline, nil means non-blocking.
+// A closed channel is recovered from and the client is unregistered
+func (h *wsHandler) sendClient(id string, ch chan MessagePayload, payload MessagePayload, expired chan struct{}) (sent bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.log(
+				errorLevel,
+				fmt.Errorf("client channel is closed:%s:%v:%s", id, r, getFunctionName()),
+			)
+			h.UnregisterClient(id)
+			sent = false
+		}
+	}()
+	if expired == nil {
+		select {
+		case ch <- payload:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case ch <- payload:
+		return true
+	case <-expired:
+		return false
+	}
+}
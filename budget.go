@@ -0,0 +1,96 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of the handler
+type HandlerStats struct {
+	// Goroutines currently running for calls, streams and batches
+	Goroutines int64
+	// Goroutine budget, 0 if not limited
+	GoroutineBudget int
+	// Workers of WithWorkers, 0 without the pool
+	Workers         int
+	DroppedPayloads uint64
+	// Highest number of unread payloads seen in a pipeline channel
+	PipelineBacklogPeak int
+	// Broadcast payloads dropped for slow or closed clients
+	DroppedBroadcasts uint64
+}
+
+// Setting the shared limit of goroutines spawned by the calls, the streaming
+// and the batching APIs, 0 disables it.
+// When the budget is exhausted the call waits for a free slot until its ctx is done.
+// A slot is taken by every running handler and by a stream handler for its whole
+// duration, the goroutines only waiting for the handlers are not counted,
+// so that a call never waits for a slot held by itself.
+// Workers of pinned handlers are not counted
+func (h *wsHandler) SetGoroutineBudget(n int) WsHandler {
+	if h.err == nil {
+		if n < 0 {
+			h.err = fmt.Errorf("not a valid goroutine budget:%d:%s", n, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.budget = nil
+		if n > 0 {
+			h.budget = make(chan struct{}, n)
+		}
+	}
+	return h
+}
+
+// Current counters of the handler
+func (h *wsHandler) Stats() HandlerStats {
+	h.mutex.RLock()
+	budget := cap(h.budget)
+	workers := 0
+	if h.workers != nil {
+		workers = h.workers.size
+	}
+	h.mutex.RUnlock()
+	return HandlerStats{
+		Goroutines:          atomic.LoadInt64(&h.goroutines),
+		GoroutineBudget:     budget,
+		Workers:             workers,
+		DroppedPayloads:     h.DroppedPayloads(),
+		PipelineBacklogPeak: h.PipelineBacklogPeak(),
+		DroppedBroadcasts:   atomic.LoadUint64(&h.clients.dropped),
+	}
+}
+
+// Taking a slot of the budget for a new goroutine, the returned func releases it.
+// The budget is a part of the configuration the caller reads under the lock
+func (h *wsHandler) acquire(ctx context.Context, meta WsFunc, cfg callConfig) (func(), error) {
+	budget := cfg.budget
+	if budget != nil {
+		start := time.Now()
+		select {
+		case budget <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		h.observeQueueWait(cfg, meta, time.Since(start))
+	}
+	done := h.counted()
+	return func() {
+		done()
+		if budget != nil {
+			<-budget
+		}
+	}, nil
+}
+
+// Counting a goroutine that takes no slot of the budget, since it only waits
+// for the handlers taking their own slots. The returned func uncounts it
+func (h *wsHandler) counted() func() {
+	atomic.AddInt64(&h.goroutines, 1)
+	return func() {
+		atomic.AddInt64(&h.goroutines, -1)
+	}
+}
@@ -0,0 +1,87 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Pipeline of the root with three children
+func fanOut(t *testing.T, budget int) (WsHandler, WsFunc) {
+	t.Helper()
+	h := newTestHandler(t).SetGoroutineBudget(budget)
+	root := WsFunc{Event: "root"}
+	parent := stage("a")
+	h.Handle(root, parent)
+	for _, name := range []string{"b", "c", "d"} {
+		h.Handle(WsFunc{Event: name}, stage(name), parent)
+	}
+	if err := h.GetError(); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	return h, root
+}
+
+func TestParallelPipelineWithinBudget(t *testing.T) {
+	for _, budget := range []int{1, 2} {
+		t.Run(fmt.Sprint(budget), func(t *testing.T) {
+			h, root := fanOut(t, budget)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			ch := make(chan MessagePayload, 8)
+			if err := h.CallPipelineFuncParallel(ctx, root, WsFuncData{Payload: MessagePayload{Event: root.Event}}, ch); err != nil {
+				t.Fatalf("call pipeline: %v", err)
+			}
+			close(ch)
+			n := 0
+			for range ch {
+				n++
+			}
+			if n != 4 {
+				t.Fatalf("%d outputs, want 4", n)
+			}
+		})
+	}
+}
+
+func TestStreamingWithinBudget(t *testing.T) {
+	for _, budget := range []int{1, 2} {
+		t.Run(fmt.Sprint(budget), func(t *testing.T) {
+			h, root := fanOut(t, budget)
+			h.HandleStream(WsFunc{Event: "ticks"}, func(ctx context.Context, data WsFuncData, emit *Emitter) error {
+				for i := 0; i < 3; i++ {
+					if err := emit.Emit(MessagePayload{Event: "ticks", Data: i}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			count := func(ch <-chan MessagePayload) (n int) {
+				for p := range ch {
+					if p.Status == ErrorLevel {
+						t.Fatalf("error output: %+v", p)
+					}
+					n++
+				}
+				return n
+			}
+			if n := count(h.CallStreaming(ctx, root, WsFuncData{Payload: MessagePayload{Event: root.Event}})); n != 4 {
+				t.Fatalf("pipeline: %d outputs, want 4", n)
+			}
+			if n := count(h.CallStreaming(ctx, WsFunc{Event: "ticks"}, WsFuncData{Payload: MessagePayload{Event: "ticks"}})); n != 3 {
+				t.Fatalf("stream: %d outputs, want 3", n)
+			}
+			n := 0
+			for batch := range h.CallPipelineBatched(ctx, root, WsFuncData{Payload: MessagePayload{Event: root.Event}}, 0) {
+				n += len(batch)
+			}
+			if n != 4 {
+				t.Fatalf("batched: %d outputs, want 4", n)
+			}
+		})
+	}
+}
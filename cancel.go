@@ -0,0 +1,111 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+)
+
+type cancelKeyCtx struct{}
+
+// Reason attached to the context of a cancelled call
+type cancelReason struct {
+	reason string
+}
+
+func (c cancelReason) Error() string {
+	return "call cancelled:" + c.reason
+}
+
+// Marks the call with a key by which it can be cancelled
+// via Cancel or CancelWithReason while it is running
+func WithCancelKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, cancelKeyCtx{}, key)
+}
+
+// Returns the reason passed to CancelWithReason, if the call was cancelled by it
+func CancelReasonFromContext(ctx context.Context) (string, bool) {
+	var r cancelReason
+	if errors.As(context.Cause(ctx), &r) {
+		return r.reason, true
+	}
+	return "", false
+}
+
+// Cancelling the active calls by key, all calls marked with the key are cancelled
+func (h *wsHandler) Cancel(key string) bool {
+	return h.cancel(key, nil)
+}
+
+// Cancelling an active call by key with the reason available to the handler
+func (h *wsHandler) CancelWithReason(key string, reason string) bool {
+	return h.cancel(key, cancelReason{reason: reason})
+}
+
+func (h *wsHandler) cancel(key string, cause error) bool {
+	h.cancelMutex.Lock()
+	defer h.cancelMutex.Unlock()
+	return cancelCalls(h.cancels, key, cause)
+}
+
+// Registers a cancel func for a call marked with WithCancelKey
+func (h *wsHandler) trackCancel(ctx context.Context) (context.Context, func()) {
+	key, ok := ctx.Value(cancelKeyCtx{}).(string)
+	if !ok || key == "" {
+		return ctx, func() {}
+	}
+	return h.trackCall(h.cancels, ctx, key)
+}
+
+// Cancelling the running pipeline by the request id of its payload,
+// all pipelines running with the same id are cancelled.
+// The stage in progress observes the done context, the remaining stages are not run,
+// also in ModeCollect
+func (h *wsHandler) CancelPipeline(id string) bool {
+	h.cancelMutex.Lock()
+	defer h.cancelMutex.Unlock()
+	return cancelCalls(h.pipelines, id, nil)
+}
+
+// Registers a cancel func for the pipeline with the request id
+func (h *wsHandler) trackPipeline(ctx context.Context, id string) (context.Context, func()) {
+	return h.trackCall(h.pipelines, ctx, id)
+}
+
+// Call registered under its key
+type trackedCall struct {
+	cancel context.CancelCauseFunc
+}
+
+// Registers a cancel func for the call with the key.
+// Every call has its own entry, so that the calls sharing the key do not replace each other
+func (h *wsHandler) trackCall(calls map[string]map[*trackedCall]struct{}, ctx context.Context, key string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	call := &trackedCall{cancel: cancel}
+	h.cancelMutex.Lock()
+	if calls[key] == nil {
+		calls[key] = make(map[*trackedCall]struct{})
+	}
+	calls[key][call] = struct{}{}
+	h.cancelMutex.Unlock()
+	return ctx, func() {
+		h.cancelMutex.Lock()
+		if tracked, ok := calls[key]; ok {
+			delete(tracked, call)
+			if len(tracked) == 0 {
+				delete(calls, key)
+			}
+		}
+		h.cancelMutex.Unlock()
+		cancel(nil)
+	}
+}
+
+// Cancelling all calls registered under the key, must be called under cancelMutex
+func cancelCalls(calls map[string]map[*trackedCall]struct{}, key string, cause error) bool {
+	tracked, ok := calls[key]
+	for call := range tracked {
+		call.cancel(cause)
+	}
+	delete(calls, key)
+	return ok
+}
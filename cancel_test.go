@@ -0,0 +1,163 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelWithReasonReachesTheHandler(t *testing.T) {
+	entered := make(chan struct{})
+	h := newTestHandler(t).Handle(WsFunc{Event: "slow"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		close(entered)
+		<-ctx.Done()
+		reason, _ := CancelReasonFromContext(ctx)
+		data.Payload.Data = reason
+		return data, ctx.Err()
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.CallFunc(WithCancelKey(context.Background(), "k"), WsFunc{Event: "slow"}, WsFuncData{Payload: MessagePayload{Event: "slow"}})
+		done <- err
+	}()
+	<-entered
+	if !h.CancelWithReason("k", "user") {
+		t.Fatal("the running call is not found")
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if h.Cancel("k") {
+		t.Fatal("the key is still registered after the call")
+	}
+}
+
+func TestCancelKeySharedByTwoCalls(t *testing.T) {
+	slowEntered, quickEntered, release := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	reasons := make(chan string, 1)
+	h := newTestHandler(t).
+		Handle(WsFunc{Event: "slow"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			close(slowEntered)
+			<-ctx.Done()
+			reason, _ := CancelReasonFromContext(ctx)
+			reasons <- reason
+			return data, ctx.Err()
+		}).
+		Handle(WsFunc{Event: "quick"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			close(quickEntered)
+			<-release
+			return data, nil
+		})
+	ctx := WithCancelKey(context.Background(), "k")
+	go h.CallFunc(ctx, WsFunc{Event: "slow"}, WsFuncData{Payload: MessagePayload{Event: "slow"}})
+	<-slowEntered
+	quick := make(chan error, 1)
+	go func() {
+		_, err := h.CallFunc(ctx, WsFunc{Event: "quick"}, WsFuncData{Payload: MessagePayload{Event: "quick"}})
+		quick <- err
+	}()
+	<-quickEntered
+	close(release)
+	if err := <-quick; err != nil {
+		t.Fatalf("quick call: %v", err)
+	}
+
+	if !h.CancelWithReason("k", "user") {
+		t.Fatal("the call left running under the key is not found")
+	}
+	select {
+	case reason := <-reasons:
+		if reason != "user" {
+			t.Fatalf("reason = %q, want user", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the call left running under the key was not cancelled")
+	}
+}
+
+func TestCancelPipelineStopsRemainingStagesInCollectMode(t *testing.T) {
+	h := newTestHandler(t).SetPipelineErrorMode(ModeCollect)
+	entered := make(chan struct{})
+	ran := make(chan struct{}, 1)
+	first := stage("a")
+	second := func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		close(entered)
+		<-ctx.Done()
+		return data, ctx.Err()
+	}
+	h.Handle(WsFunc{Event: "first"}, first)
+	h.Handle(WsFunc{Event: "second"}, second, first)
+	h.Handle(WsFunc{Event: "third"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		ran <- struct{}{}
+		return data, nil
+	}, second)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.CallPipelineFuncSync(context.Background(), WsFunc{Event: "first"}, WsFuncData{Payload: MessagePayload{Event: "first", RequestID: "r1"}})
+		done <- err
+	}()
+	<-entered
+	if !h.CancelPipeline("r1") {
+		t.Fatal("the running pipeline is not found")
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("the cancelled pipeline returned no error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the cancelled pipeline did not return")
+	}
+	select {
+	case <-ran:
+		t.Fatal("the third stage ran after the cancel")
+	default:
+	}
+}
+
+func TestCancelPipelineWithSharedRequestID(t *testing.T) {
+	h := newTestHandler(t)
+	gates := map[string]chan struct{}{"one": make(chan struct{}), "two": make(chan struct{})}
+	entered := make(chan struct{}, 2)
+	h.Handle(WsFunc{Event: "gated"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		entered <- struct{}{}
+		select {
+		case <-gates[data.Payload.Data.(string)]:
+			return data, nil
+		case <-ctx.Done():
+			return data, ctx.Err()
+		}
+	})
+	call := func(gate string) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := h.CallPipelineFuncSync(context.Background(), WsFunc{Event: "gated"}, WsFuncData{Payload: MessagePayload{Event: "gated", RequestID: "dup", Data: gate}})
+			done <- err
+		}()
+		return done
+	}
+	one, two := call("one"), call("two")
+	<-entered
+	<-entered
+
+	close(gates["one"])
+	if err := <-one; err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if !h.CancelPipeline("dup") {
+		t.Fatal("the second call is not found after the first one returned")
+	}
+	select {
+	case err := <-two:
+		if err == nil {
+			t.Fatal("the cancelled call returned no error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the second call was not cancelled")
+	}
+	if h.CancelPipeline("dup") {
+		t.Fatal("a pipeline is still tracked after all calls returned")
+	}
+}
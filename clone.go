@@ -0,0 +1,170 @@
+package websockethandler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Copying the registrations and the configuration into an independent handler,
+// e.g. for the dispatch table of a tenant. The clone has its own lock and error state,
+// registering or deregistering on it does not change the original.
+// The runtime state is not copied: memoized results, metrics, last errors,
+// broadcast clients, quota and rate limit counters, calls in flight.
+// The worker pool of WithWorkers and the workers of the pinned functions are shared
+// with the original, only the original stops the pinned workers.
+// A custom dispatcher is shared, it keeps calling the handler it was built with
+func (h *wsHandler) Clone() WsHandler {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	h.logMutex.RLock()
+	defer h.logMutex.RUnlock()
+	c := &wsHandler{
+		fun:         maps.Clone(h.fun),
+		funcTree:    make(map[handlerID]*wsHandlerTree, len(h.funcTree)),
+		funcIDs:     maps.Clone(h.funcIDs),
+		lastID:      h.lastID,
+		multi:       maps.Clone(h.multi),
+		streams:     maps.Clone(h.streams),
+		pinned:      make(map[WsFunc]func()),
+		noTransform: maps.Clone(h.noTransform),
+		memo:        make(map[WsFunc]*memoCache, len(h.memo)),
+		aliases:     maps.Clone(h.aliases),
+		duplicates:  slices.Clone(h.duplicates),
+
+		logger:        h.logger,
+		logLevel:      h.logLevel,
+		loggerLevel:   h.loggerLevel,
+		logSource:     h.logSource,
+		module:        h.module,
+		groupModules:  maps.Clone(h.groupModules),
+		logMaxBody:    h.logMaxBody,
+		fatalBehavior: h.fatalBehavior,
+		now:           h.now,
+		err:           h.err,
+
+		clientIdentity: h.clientIdentity,
+		codec:          h.codec,
+
+		emptyOutput:      h.emptyOutput,
+		dispatcher:       h.dispatcher,
+		testMode:         h.testMode,
+		reportTiming:     h.reportTiming,
+		streamBufferSize: h.streamBufferSize,
+		transformer:      h.transformer,
+		normalizer:       h.normalizer,
+
+		pipelineErrorMode: h.pipelineErrorMode,
+		branchStrategy:    h.branchStrategy,
+		pipelineTimeout:   h.pipelineTimeout,
+		backlogLimit:      h.backlogLimit,
+		backlogPolicy:     h.backlogPolicy,
+		workers:           h.workers,
+
+		defaultHandler: h.defaultHandler,
+		panicHandler:   h.panicHandler,
+		onError:        h.onError,
+		decorators:     slices.Clone(h.decorators),
+		accessLogger:   h.accessLogger,
+		deadLetter:     h.deadLetter,
+		errorFormat:    h.errorFormat,
+		onConnect:      h.onConnect,
+		onDisconnect:   h.onDisconnect,
+		metricsSink:    h.metricsSink,
+
+		middlewareFirst: slices.Clone(h.middlewareFirst),
+		middleware:      slices.Clone(h.middleware),
+		middlewareLast:  slices.Clone(h.middlewareLast),
+		eventMiddleware: make(map[WsFunc][]Middleware, len(h.eventMiddleware)),
+		groups:          make(map[WsFunc]*wsHandlerGroup, len(h.groups)),
+		scopes:          maps.Clone(h.scopes),
+		retries:         maps.Clone(h.retries),
+		validators:      maps.Clone(h.validators),
+		limits:          make(map[WsFunc]chan struct{}, len(h.limits)),
+		cancels:         make(map[string]map[*trackedCall]struct{}),
+		pipelines:       make(map[string]map[*trackedCall]struct{}),
+		lastErrors:      lastErrors{errs: make(map[WsFunc]lastError)},
+		metrics:         metrics{events: make(map[WsFunc]EventMetrics)},
+		clients:         clients{chans: make(map[string]chan MessagePayload), timeout: h.clients.timeout},
+	}
+
+	nodes := make(map[*wsHandlerTree]*wsHandlerTree)
+	for id, node := range h.funcTree {
+		c.funcTree[id] = cloneNode(nodes, node)
+	}
+	c.defaultPipeline = cloneNode(nodes, h.defaultPipeline)
+
+	for meta, m := range h.memo {
+		c.memo[meta] = &memoCache{
+			maxEntries: m.maxEntries,
+			order:      list.New(),
+			entries:    make(map[[sha256.Size]byte]*list.Element),
+		}
+	}
+	for meta, mw := range h.eventMiddleware {
+		c.eventMiddleware[meta] = slices.Clone(mw)
+	}
+	groups := make(map[*wsHandlerGroup]*wsHandlerGroup)
+	for meta, g := range h.groups {
+		cg, ok := groups[g]
+		if !ok {
+			cg = &wsHandlerGroup{h: c, prefix: g.prefix, middleware: slices.Clone(g.middleware)}
+			groups[g] = cg
+		}
+		c.groups[meta] = cg
+	}
+	for meta, limit := range h.limits {
+		c.limits[meta] = make(chan struct{}, cap(limit))
+	}
+	if h.budget != nil {
+		c.budget = make(chan struct{}, cap(h.budget))
+	}
+
+	if h.sampler != nil {
+		c.sampler = &logSampler{n: h.sampler.n, start: time.Now(), counts: make(map[sampleKey]int)}
+	}
+	if h.quota != nil {
+		c.quota = &clientQuota{
+			max:       h.quota.max,
+			window:    h.quota.window,
+			keyFunc:   h.quota.keyFunc,
+			counters:  make(map[string]*quotaCounter),
+			lastSweep: time.Now(),
+		}
+	}
+	if h.rateLimit != nil {
+		c.rateLimit = &rateLimiter{
+			rate:      h.rateLimit.rate,
+			burst:     h.rateLimit.burst,
+			buckets:   make(map[string]*tokenBucket),
+			lastSweep: time.Now(),
+		}
+	}
+
+	h.readiness.mutex.RLock()
+	c.readiness = readiness{notReady: maps.Clone(h.readiness.notReady)}
+	h.readiness.mutex.RUnlock()
+	return c
+}
+
+// Copying the node with its parent and children, every node is copied once
+func cloneNode(nodes map[*wsHandlerTree]*wsHandlerTree, node *wsHandlerTree) *wsHandlerTree {
+	if node == nil {
+		return nil
+	}
+	if c, ok := nodes[node]; ok {
+		return c
+	}
+	c := &wsHandlerTree{id: node.id, meta: node.meta, main: node.main}
+	nodes[node] = c
+	c.parent = cloneNode(nodes, node.parent)
+	if node.children != nil {
+		c.children = make([]*wsHandlerTree, len(node.children))
+		for i, child := range node.children {
+			c.children[i] = cloneNode(nodes, child)
+		}
+	}
+	return c
+}
@@ -0,0 +1,65 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCloneRegistrationsAreIndependent(t *testing.T) {
+	shared, added := WsFunc{Event: "shared"}, WsFunc{Event: "added"}
+	h := newTestHandler(t).Handle(shared, stage("s"))
+	c := h.Clone().Handle(added, stage("a")).Deregister(shared)
+	if err := c.GetError(); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+
+	if _, err := h.CallFunc(context.Background(), shared, WsFuncData{Payload: MessagePayload{Event: shared.Event}}); err != nil {
+		t.Fatalf("deregistering on the clone removed the original handler: %v", err)
+	}
+	if _, err := h.CallFunc(context.Background(), added, WsFuncData{Payload: MessagePayload{Event: added.Event}}); !errors.Is(err, ErrHandlerNotRegistered) {
+		t.Fatalf("err = %v, want the handler of the clone missing in the original", err)
+	}
+	if _, err := c.CallFunc(context.Background(), shared, WsFuncData{Payload: MessagePayload{Event: shared.Event}}); !errors.Is(err, ErrHandlerNotRegistered) {
+		t.Fatalf("err = %v, want the deregistered handler missing in the clone", err)
+	}
+}
+
+func TestClonePreservesThePipeline(t *testing.T) {
+	root, next, extra := WsFunc{Event: "root"}, WsFunc{Event: "next"}, WsFunc{Event: "extra"}
+	parent := stage("a")
+	h := newTestHandler(t).Handle(root, parent).Handle(next, stage("b"), parent)
+	c := h.Clone()
+
+	chain, err := c.PipelineChain(root)
+	if err != nil || !slices.Equal(chain, []WsFunc{root, next}) {
+		t.Fatalf("chain of the clone = %v, %v, want [%s %s]", chain, err, root, next)
+	}
+	out, err := c.CallPipelineFuncSync(context.Background(), root, WsFuncData{Payload: MessagePayload{Event: root.Event}})
+	if err != nil || len(out) != 2 || out[0].Data != "a" || out[1].Data != "b" {
+		t.Fatalf("pipeline of the clone = %+v, %v, want Data a and b", out, err)
+	}
+
+	if err := c.HandleE(extra, stage("x"), parent); err != nil {
+		t.Fatalf("handle on the clone: %v", err)
+	}
+	out, err = h.CallPipelineFuncSync(context.Background(), root, WsFuncData{Payload: MessagePayload{Event: root.Event}})
+	if err != nil || len(out) != 2 {
+		t.Fatalf("pipeline of the original = %+v, %v, want the two stages", out, err)
+	}
+}
+
+func TestCloneHasItsOwnErrorState(t *testing.T) {
+	h := newTestHandler(t)
+	c := h.Clone().SetConcurrencyLimit(WsFunc{Event: "limited"}, -1)
+	if c.GetError() == nil {
+		t.Fatal("the invalid setter did not fail on the clone")
+	}
+	if err := h.GetError(); err != nil {
+		t.Fatalf("the error of the clone is set on the original: %v", err)
+	}
+	if err := h.HandleE(WsFunc{Event: "later"}, stage("l")); err != nil {
+		t.Fatalf("handle on the original: %v", err)
+	}
+}
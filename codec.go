@@ -0,0 +1,42 @@
+package websockethandler
+
+import "encoding/json"
+
+// Serialization of the payloads on the wire, used by the connection layer
+type Codec interface {
+	Marshal(payload MessagePayload) ([]byte, error)
+	Unmarshal(data []byte) (MessagePayload, error)
+}
+
+// Codec of the JSON text frames, the default one
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(payload MessagePayload) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+func (JSONCodec) Unmarshal(data []byte) (MessagePayload, error) {
+	var payload MessagePayload
+	err := json.Unmarshal(data, &payload)
+	return payload, err
+}
+
+// Setting the codec of the connection layer, nil restores JSONCodec
+func (h *wsHandler) SetCodec(c Codec) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if c == nil {
+			c = JSONCodec{}
+		}
+		h.codec = c
+	}
+	return h
+}
+
+// The codec set by SetCodec
+func (h *wsHandler) Codec() Codec {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.codec
+}
@@ -0,0 +1,50 @@
+package websockethandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// Codec of the frames "event|data"
+type pipeCodec struct{}
+
+func (pipeCodec) Marshal(payload MessagePayload) ([]byte, error) {
+	data, _ := payload.Data.(string)
+	return []byte(payload.Event + "|" + data), nil
+}
+
+func (pipeCodec) Unmarshal(frame []byte) (MessagePayload, error) {
+	event, data, ok := bytes.Cut(frame, []byte("|"))
+	if !ok {
+		return MessagePayload{}, errors.New("no separator")
+	}
+	return MessagePayload{Event: string(event), Data: string(data)}, nil
+}
+
+func TestHandleRawDecodesByTheCodec(t *testing.T) {
+	var raw []byte
+	h := newTestHandler(t).SetCodec(pipeCodec{}).Handle(WsFunc{Event: "echo"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		raw = RawBytesFromContext(ctx)
+		return data, nil
+	})
+
+	out, err := h.HandleRaw(context.Background(), "ann", []byte("echo|hello"))
+	if err != nil || out.Payload.Data != "hello" {
+		t.Fatalf("HandleRaw = %+v, %v, want Data hello", out.Payload, err)
+	}
+	if string(raw) != "echo|hello" {
+		t.Fatalf("raw bytes = %q, want the frame", raw)
+	}
+	if out, err := h.HandleRaw(context.Background(), "ann", []byte(`{"event":"echo"}`)); err == nil || out.Payload.Status != ErrorLevel {
+		t.Fatalf("HandleRaw of a JSON frame = %+v, %v, want the decoding error", out.Payload, err)
+	}
+}
+
+func TestSetCodecNilRestoresJSON(t *testing.T) {
+	h := newTestHandler(t).SetCodec(pipeCodec{}).SetCodec(nil)
+	if _, ok := h.Codec().(JSONCodec); !ok {
+		t.Fatalf("codec = %T, want JSONCodec", h.Codec())
+	}
+}
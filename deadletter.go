@@ -0,0 +1,28 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Setting the sink of messages whose handler failed terminally.
+// The sink is provided by the caller, e.g. a persistent queue for later Replay
+func (h *wsHandler) SetDeadLetter(f func(meta WsFunc, data WsFuncData, err error)) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.deadLetter = f
+	}
+	return h
+}
+
+// Running the captured message through the currently registered handler
+func (h *wsHandler) Replay(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	h.log(
+		infoLevel,
+		fmt.Errorf("replay:%s:%s", meta, getFunctionName()),
+		data.Payload,
+		data.Client,
+	)
+	return h.CallFunc(ctx, meta, data)
+}
@@ -0,0 +1,110 @@
+package websockethandler
+
+import "fmt"
+
+// Removing the registration of the event.
+// A function with a child function declaration is not removed and the error is set,
+// DeregisterCascade removes it along with the children.
+// Deregistering an event that is not registered does nothing
+func (h *wsHandler) Deregister(meta WsFunc) WsHandler {
+	return h.deregister(meta, false)
+}
+
+// Removing the registration of the event and of all its child functions
+func (h *wsHandler) DeregisterCascade(meta WsFunc) WsHandler {
+	return h.deregister(meta, true)
+}
+
+func (h *wsHandler) deregister(meta WsFunc, cascade bool) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		meta = h.normalize(meta)
+		if _, ok := h.multi[meta]; ok {
+			delete(h.multi, meta)
+			h.forget(meta)
+			return h
+		}
+		if _, ok := h.streams[meta]; ok {
+			delete(h.streams, meta)
+			h.forget(meta)
+			return h
+		}
+		f, ok := h.fun[meta]
+		if !ok {
+			return h
+		}
+
+		node, ok := h.nodeOf(f)
+		if !ok {
+			// Pinned functions are not a part of the tree
+			delete(h.fun, meta)
+			h.stopPinned(meta)
+			h.forget(meta)
+			return h
+		}
+		if len(node.children) > 0 && !cascade {
+			h.err = fmt.Errorf("the function has a child function declaration:%s:%s", meta, getFunctionName())
+			return h
+		}
+
+		delete(h.fun, meta)
+		h.forget(meta)
+		if h.keyInUse(keyOf(f)) {
+			// The node is shared with another registration of the same function
+			return h
+		}
+		detach(node)
+		h.removeSubtree(node)
+	}
+	return h
+}
+
+// Removing the node from the children of its parent. The children are copied,
+// so that a pipeline ranging over them while the lock is released is not affected
+func detach(node *wsHandlerTree) {
+	if node.parent == nil {
+		return
+	}
+	siblings := make([]*wsHandlerTree, 0, len(node.parent.children))
+	for _, child := range node.parent.children {
+		if child != node {
+			siblings = append(siblings, child)
+		}
+	}
+	node.parent.children = siblings
+}
+
+// Removing the node with all its children and their registrations
+func (h *wsHandler) removeSubtree(node *wsHandlerTree) {
+	key := keyOf(node.main)
+	for m, fn := range h.fun {
+		if keyOf(fn) == key {
+			delete(h.fun, m)
+			h.forget(m)
+		}
+	}
+	h.removeNode(node)
+	for _, child := range node.children {
+		h.removeSubtree(child)
+	}
+}
+
+// Reports whether a registered meta still refers to the tree key
+func (h *wsHandler) keyInUse(key funcKey) bool {
+	for _, fn := range h.fun {
+		if keyOf(fn) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Dropping the settings bound to the registration of the event
+func (h *wsHandler) forget(meta WsFunc) {
+	delete(h.groups, meta)
+	delete(h.eventMiddleware, meta)
+	delete(h.memo, meta)
+	delete(h.scopes, meta)
+	delete(h.validators, meta)
+}
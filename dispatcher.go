@@ -0,0 +1,73 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Resolution and execution of the handler for CallFunc.
+// A custom dispatcher is called without the lock of the handler,
+// the dispatcher returned by DefaultDispatcher takes the read lock itself
+type Dispatcher interface {
+	Dispatch(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error)
+}
+
+// Exact lookup of the meta in the registered functions,
+// the default handler is called for events that are not registered
+type defaultDispatcher struct {
+	h *wsHandler
+}
+
+func (d defaultDispatcher) Dispatch(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	d.h.mutex.RLock()
+	defer d.h.mutex.RUnlock()
+	return d.h.dispatch(ctx, meta, data)
+}
+
+// The default dispatch, must be called under the read lock
+func (h *wsHandler) dispatch(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	if f, ok := h.fun[meta]; ok {
+		out, err := h.shell(f, ctx, meta, data)
+		if err != nil {
+			return out, fmt.Errorf("%w:%s:%s", err, meta, getFunctionName())
+		}
+		return out, nil
+	}
+	if h.defaultHandler != nil {
+		out, err := h.shell(h.defaultHandler, ctx, WsFunc{}, data)
+		if err != nil {
+			return out, fmt.Errorf("%w:%s:%s", err, meta, getFunctionName())
+		}
+		return out, nil
+	}
+	return h.notRegistered(meta, data)
+}
+
+// Setting the dispatcher used by CallFunc, nil restores the default one
+func (h *wsHandler) SetDispatcher(d Dispatcher) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.dispatcher = d
+	}
+	return h
+}
+
+// The dispatcher with the default behavior,
+// can be wrapped by a custom dispatcher
+func (h *wsHandler) DefaultDispatcher() Dispatcher {
+	return defaultDispatcher{h: h}
+}
+
+// Setting the handler called by CallFunc and CallPipelineFunc for events that are
+// not registered, instead of returning the error. Nil restores the error.
+// In pipeline mode the default pipeline, if set, is run instead.
+// Calls are recorded under the zero WsFunc, the event is in the payload
+func (h *wsHandler) SetDefaultHandler(f HandlerFunc) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.defaultHandler = f
+	}
+	return h
+}
@@ -0,0 +1,39 @@
+package websockethandler
+
+import (
+	"context"
+	"testing"
+)
+
+type wrappingDispatcher struct {
+	next  Dispatcher
+	calls int
+}
+
+func (d *wrappingDispatcher) Dispatch(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	d.calls++
+	return d.next.Dispatch(ctx, meta, data)
+}
+
+func TestDefaultDispatcherOutsideOfCallFunc(t *testing.T) {
+	h := newTestHandler(t)
+	h.Handle(WsFunc{Event: "direct"}, stage("d"))
+	out, err := h.DefaultDispatcher().Dispatch(context.Background(), WsFunc{Event: "direct"}, WsFuncData{Payload: MessagePayload{Event: "direct"}})
+	if err != nil || out.Payload.Data != "d" {
+		t.Fatalf("dispatch = %v, %v, want Data d", out.Payload.Data, err)
+	}
+}
+
+func TestCustomDispatcherWrapsTheDefault(t *testing.T) {
+	h := newTestHandler(t)
+	h.Handle(WsFunc{Event: "wrapped"}, stage("w"))
+	d := &wrappingDispatcher{next: h.DefaultDispatcher()}
+	h.SetDispatcher(d)
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "wrapped"}, WsFuncData{Payload: MessagePayload{Event: "wrapped"}})
+	if err != nil || out.Payload.Data != "w" {
+		t.Fatalf("call = %v, %v, want Data w", out.Payload.Data, err)
+	}
+	if d.calls != 1 {
+		t.Fatalf("custom dispatcher called %d times, want 1", d.calls)
+	}
+}
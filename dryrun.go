@@ -0,0 +1,19 @@
+package websockethandler
+
+import "context"
+
+type dryRunCtx struct{}
+
+// Reports whether the call was started by CallFuncDryRun.
+// Handlers honoring it should validate the input and skip any writes
+func DryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunCtx{}).(bool)
+	return dry
+}
+
+// Calling an event in dry-run mode for probes and canary checks.
+// The mode is only a signal: handlers ignoring DryRun run as usual,
+// including their side effects
+func (h *wsHandler) CallFuncDryRun(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	return h.CallFunc(context.WithValue(ctx, dryRunCtx{}, true), meta, data)
+}
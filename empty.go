@@ -0,0 +1,62 @@
+package websockethandler
+
+import "fmt"
+
+// Behavior when a handler returns an empty WsFuncData without an error
+type EmptyOutputPolicy uint8
+
+const (
+	// The empty output is returned as is
+	EmptyOutputKeep EmptyOutputPolicy = iota
+	// The input event is echoed back with the info status
+	EmptyOutputEcho
+	// The empty output is returned as is and a warning is logged
+	EmptyOutputWarn
+)
+
+// Setting the behavior for handlers returning an empty output
+func (h *wsHandler) SetEmptyOutputPolicy(policy EmptyOutputPolicy) WsHandler {
+	if h.err == nil {
+		if policy > EmptyOutputWarn {
+			h.err = fmt.Errorf("not a valid empty output policy:%d:%s", policy, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.emptyOutput = policy
+	}
+	return h
+}
+
+func isEmptyOutput(d WsFuncData) bool {
+	return d.Client == nil &&
+		d.Payload.Event == "" &&
+		d.Payload.Status == "" &&
+		d.Payload.Data == nil &&
+		len(d.Payload.Warnings) == 0 &&
+		len(d.Attachments) == 0
+}
+
+func (h *wsHandler) applyEmptyOutputPolicy(policy EmptyOutputPolicy, in, out WsFuncData) WsFuncData {
+	if policy == EmptyOutputKeep || !isEmptyOutput(out) {
+		return out
+	}
+	switch policy {
+	case EmptyOutputEcho:
+		return WsFuncData{
+			Client: in.Client,
+			Payload: MessagePayload{
+				Event:  in.Payload.Event,
+				Status: InfoLevel,
+			},
+		}
+	case EmptyOutputWarn:
+		h.log(
+			warnLevel,
+			fmt.Errorf("handler returned an empty output:%s", getFunctionName()),
+			in.Payload,
+			in.Client,
+		)
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+)
+
+// Machine-readable error code delivered to clients
+type ErrorCode string
+
+const (
+	CodeNotFound     ErrorCode = "not_found"
+	CodeInvalid      ErrorCode = "invalid"
+	CodeUnauthorized ErrorCode = "unauthorized"
+	CodeInternal     ErrorCode = "internal"
+	CodeTimeout      ErrorCode = "timeout"
+	CodeCancelled    ErrorCode = "cancelled"
+)
+
+// Handler error with the code, e.g. CodedError{Code: CodeInvalid, Err: err}
+type CodedError struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e CodedError) Error() string {
+	if e.Err == nil {
+		return string(e.Code)
+	}
+	return string(e.Code) + ":" + e.Err.Error()
+}
+
+func (e CodedError) Unwrap() error {
+	return e.Err
+}
+
+// HTTP status code corresponding to the error code
+func (c ErrorCode) HTTPStatus() int {
+	switch c {
+	case CodeNotFound:
+		return 404
+	case CodeInvalid:
+		return 400
+	case CodeUnauthorized:
+		return 401
+	case CodeTimeout:
+		return 504
+	}
+	return 500
+}
+
+// Code of the error: the code of CodedError in the chain,
+// CodeNotFound for an event that is not registered,
+// CodeTimeout for an exceeded deadline, CodeCancelled for a cancelled call
+// and CodeInternal otherwise
+func ErrorCodeOf(err error) ErrorCode {
+	var coded CodedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	if errors.Is(err, ErrHandlerNotRegistered) {
+		return CodeNotFound
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return CodeTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return CodeCancelled
+	}
+	return CodeInternal
+}
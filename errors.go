@@ -0,0 +1,14 @@
+package websockethandler
+
+import "errors"
+
+var (
+	ErrAlreadyRegistered    = errors.New("func with current params has been registered")
+	ErrHandlerNotRegistered = errors.New("func with current params has not been registered")
+	ErrNoParent             = errors.New("there is no registered parent function")
+	ErrTimeout              = errors.New("timeout reached")
+	ErrQuotaExceeded        = errors.New("quota exceeded")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrNotReady             = errors.New("not ready")
+	ErrShuttingDown         = errors.New("shutting down")
+)
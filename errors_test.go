@@ -0,0 +1,50 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErrAlreadyRegistered(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "first"}, stage("a"))
+	if err := h.HandleE(WsFunc{Event: "first"}, stage("b")); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("err = %v, want ErrAlreadyRegistered", err)
+	}
+}
+
+func TestErrHandlerNotRegistered(t *testing.T) {
+	h := newTestHandler(t)
+	missing := WsFunc{Event: "missing"}
+	if _, err := h.CallFunc(context.Background(), missing, WsFuncData{Payload: MessagePayload{Event: missing.Event}}); !errors.Is(err, ErrHandlerNotRegistered) {
+		t.Fatalf("CallFunc err = %v, want ErrHandlerNotRegistered", err)
+	}
+	ch := make(chan MessagePayload, 1)
+	if err := h.CallPipelineFunc(context.Background(), missing, WsFuncData{Payload: MessagePayload{Event: missing.Event}}, ch); !errors.Is(err, ErrHandlerNotRegistered) {
+		t.Fatalf("CallPipelineFunc err = %v, want ErrHandlerNotRegistered", err)
+	}
+}
+
+func TestErrNoParent(t *testing.T) {
+	h := newTestHandler(t)
+	if err := h.HandleE(WsFunc{Event: "child"}, stage("b"), stage("a")); !errors.Is(err, ErrNoParent) {
+		t.Fatalf("err = %v, want ErrNoParent", err)
+	}
+}
+
+func TestErrTimeout(t *testing.T) {
+	slow := WsFunc{Event: "slow"}
+	h := newTestHandler(t).Handle(slow, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		<-ctx.Done()
+		return data, nil
+	})
+	ctx := WithCallTimeout(context.Background(), 10*time.Millisecond)
+	out, err := h.CallFunc(ctx, slow, WsFuncData{Payload: MessagePayload{Event: slow.Event}})
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want ErrTimeout and context.DeadlineExceeded", err)
+	}
+	if out.Payload.Code != CodeTimeout {
+		t.Fatalf("payload = %+v, want CodeTimeout", out.Payload)
+	}
+}
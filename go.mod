@@ -2,4 +2,7 @@ module github.com/bydanovm/websockethandler
 
 go 1.22.0
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
+)
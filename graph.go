@@ -0,0 +1,128 @@
+package websockethandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+type graphNode struct {
+	Event  string `json:"event"`
+	Status string `json:"status,omitempty"`
+	Name   string `json:"name"`
+}
+
+type graphRef struct {
+	Event  string `json:"event"`
+	Status string `json:"status,omitempty"`
+}
+
+type graphEdge struct {
+	Parent graphRef `json:"parent"`
+	Child  graphRef `json:"child"`
+}
+
+type graph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// Exporting the registered handlers and the parent->child links of pipelines as JSON
+func (h *wsHandler) ExportGraph() ([]byte, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	metas := make(map[funcKey]WsFunc, len(h.fun))
+	g := graph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+	for meta, f := range h.fun {
+		metas[keyOf(f)] = meta
+		g.Nodes = append(g.Nodes, graphNode{
+			Event:  meta.Event,
+			Status: meta.Status,
+			Name:   getHandlerName(f),
+		})
+	}
+	for _, node := range h.funcTree {
+		if len(node.children) == 0 {
+			continue
+		}
+		parent, ok := metas[keyOf(node.main)]
+		if !ok {
+			return nil, fmt.Errorf("there is no registered meta for function:%s:%s", getHandlerName(node.main), getFunctionName())
+		}
+		for _, childNode := range node.children {
+			child, ok := metas[keyOf(childNode.main)]
+			if !ok {
+				return nil, fmt.Errorf("there is no registered meta for child function:%s:%s", getHandlerName(childNode.main), getFunctionName())
+			}
+			g.Edges = append(g.Edges, graphEdge{
+				Parent: graphRef{Event: parent.Event, Status: parent.Status},
+				Child:  graphRef{Event: child.Event, Status: child.Status},
+			})
+		}
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Event != g.Nodes[j].Event {
+			return g.Nodes[i].Event < g.Nodes[j].Event
+		}
+		return g.Nodes[i].Status < g.Nodes[j].Status
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Parent != g.Edges[j].Parent {
+			if g.Edges[i].Parent.Event != g.Edges[j].Parent.Event {
+				return g.Edges[i].Parent.Event < g.Edges[j].Parent.Event
+			}
+			return g.Edges[i].Parent.Status < g.Edges[j].Parent.Status
+		}
+		if g.Edges[i].Child.Event != g.Edges[j].Child.Event {
+			return g.Edges[i].Child.Event < g.Edges[j].Child.Event
+		}
+		return g.Edges[i].Child.Status < g.Edges[j].Child.Status
+	})
+
+	b, err := json.Marshal(g)
+	if err != nil {
+		return nil, fmt.Errorf("%w:%s", err, getFunctionName())
+	}
+	return b, nil
+}
+
+// Events of the stages CallPipelineFunc runs for the event, in order.
+// Branches are listed depth-first in the order of registration,
+// the branch strategy may skip some of them
+func (h *wsHandler) PipelineChain(meta WsFunc) ([]WsFunc, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	meta = h.lookupAlias(meta)
+	f, ok := h.fun[meta]
+	if !ok {
+		return nil, fmt.Errorf("%w:%s:%s", ErrHandlerNotRegistered, meta, getFunctionName())
+	}
+	node, ok := h.nodeOf(f)
+	if !ok {
+		return nil, fmt.Errorf("%w:for pipeline:%s:%s", ErrHandlerNotRegistered, meta, getFunctionName())
+	}
+	return chainOf(node), nil
+}
+
+// Chains of all pipeline roots, a root without children is a chain of one event
+func (h *wsHandler) Pipelines() map[WsFunc][]WsFunc {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	pipelines := make(map[WsFunc][]WsFunc)
+	for _, node := range h.funcTree {
+		if node.parent == nil {
+			pipelines[node.meta] = chainOf(node)
+		}
+	}
+	return pipelines
+}
+
+func chainOf(node *wsHandlerTree) []WsFunc {
+	chain := []WsFunc{node.meta}
+	for _, child := range node.children {
+		chain = append(chain, chainOf(child)...)
+	}
+	return chain
+}
@@ -0,0 +1,112 @@
+package websockethandler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Set of events sharing the event prefix and the middleware
+type HandlerGroup interface {
+	Handle(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) HandlerGroup
+	Use(mw Middleware) HandlerGroup
+	SetModuleName(name string) HandlerGroup
+	GetError() error
+}
+
+type wsHandlerGroup struct {
+	h          *wsHandler
+	prefix     string
+	middleware []Middleware
+}
+
+// Creating the group of events with the prefix, e.g. "order."
+func (h *wsHandler) Group(prefix string) HandlerGroup {
+	return &wsHandlerGroup{h: h, prefix: prefix}
+}
+
+// Registration of the function for the prefixed event.
+// The parent function must be registered in the same handler
+func (g *wsHandlerGroup) Handle(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) HandlerGroup {
+	meta.Event = g.prefix + meta.Event
+	g.h.Handle(meta, f, parent...)
+	g.h.mutex.Lock()
+	defer g.h.mutex.Unlock()
+	if g.h.err == nil {
+		g.h.groups[g.h.normalize(meta)] = g
+	}
+	return g
+}
+
+// Adding the middleware applied only to the events of the group,
+// it runs after the global middleware and before the per-event one
+func (g *wsHandlerGroup) Use(mw Middleware) HandlerGroup {
+	g.h.mutex.Lock()
+	defer g.h.mutex.Unlock()
+	if g.h.err == nil {
+		g.middleware = append(g.middleware, mw)
+	}
+	return g
+}
+
+// Setting the module name of the log entries of the group events,
+// an empty name restores the module name of the handler
+func (g *wsHandlerGroup) SetModuleName(name string) HandlerGroup {
+	if g.h.err == nil {
+		g.h.logMutex.Lock()
+		defer g.h.logMutex.Unlock()
+		if name == "" {
+			delete(g.h.groupModules, g.prefix)
+			return g
+		}
+		g.h.groupModules[g.prefix] = name
+	}
+	return g
+}
+
+// Module name of the log entry: the one of the group with the longest prefix
+// of the event in the entry data, otherwise the one of the handler.
+// Must be called under the log lock
+func (h *wsHandler) moduleOf(data []interface{}) string {
+	event, ok := eventOf(data)
+	if !ok || len(h.groupModules) == 0 {
+		return h.module
+	}
+	module, longest := h.module, -1
+	for prefix, name := range h.groupModules {
+		if len(prefix) > longest && strings.HasPrefix(event, prefix) {
+			module, longest = name, len(prefix)
+		}
+	}
+	return module
+}
+
+func eventOf(data []interface{}) (string, bool) {
+	for _, v := range data {
+		switch v := v.(type) {
+		case MessagePayload:
+			return v.Event, true
+		case WsFuncData:
+			return v.Payload.Event, true
+		}
+	}
+	return "", false
+}
+
+func (g *wsHandlerGroup) GetError() error {
+	return g.h.GetError()
+}
+
+// Adding the middleware applied only to the event
+func (h *wsHandler) UseFor(meta WsFunc, mw Middleware) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		meta = h.normalize(meta)
+		if !h.isRegistered(meta) {
+			h.err = fmt.Errorf("%w:%s:%s", ErrHandlerNotRegistered, meta, getFunctionName())
+			return h
+		}
+		h.eventMiddleware[meta] = append(h.eventMiddleware[meta], mw)
+	}
+	return h
+}
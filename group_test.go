@@ -0,0 +1,78 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+// Middleware appending its name to the trail in Data before the handler runs
+func tracing(name string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			trail, _ := data.Payload.Data.(string)
+			data.Payload.Data = trail + name + ">"
+			return next(ctx, data)
+		}
+	}
+}
+
+// Handler returning the trail it has received
+func trail(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+	return data, nil
+}
+
+func TestGroupMiddlewareOrder(t *testing.T) {
+	h := newTestHandler(t).Use(tracing("global"))
+	orders := h.Group("order.").Use(tracing("orders"))
+	orders.Handle(WsFunc{Event: "create"}, trail)
+	h.UseFor(WsFunc{Event: "order.create"}, tracing("event"))
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "order.create"}, WsFuncData{Payload: MessagePayload{Event: "order.create"}})
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if out.Payload.Data != "global>orders>event>" {
+		t.Fatalf("trail = %v, want global>orders>event>", out.Payload.Data)
+	}
+}
+
+func TestGroupMiddlewareIsScopedToTheGroup(t *testing.T) {
+	h := newTestHandler(t)
+	h.Group("order.").Use(tracing("orders")).Handle(WsFunc{Event: "create"}, trail)
+	h.Group("user.").Use(tracing("users")).Handle(WsFunc{Event: "create"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, nil
+	})
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "user.create"}, WsFuncData{Payload: MessagePayload{Event: "user.create"}})
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if trail, _ := out.Payload.Data.(string); strings.Contains(trail, "orders") || trail != "users>" {
+		t.Fatalf("trail = %v, want users> only", out.Payload.Data)
+	}
+}
+
+func TestGroupModuleNameTagsTheLogEntries(t *testing.T) {
+	logs := &logBuffer{}
+	h := newTestHandler(t).AddLogger(log.New(logs, "", 0)).SetModuleName("app")
+	failing := func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, errors.New("failed")
+	}
+	h.Group("order.").SetModuleName("orders").Handle(WsFunc{Event: "create"}, failing)
+	h.Handle(WsFunc{Event: "ping"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, errors.New("failed")
+	})
+
+	h.CallFunc(context.Background(), WsFunc{Event: "order.create"}, WsFuncData{Payload: MessagePayload{Event: "order.create"}})
+	if out := logs.String(); !strings.Contains(out, " orders ") {
+		t.Fatalf("log %q has no entry of the module orders", out)
+	}
+	h.CallFunc(context.Background(), WsFunc{Event: "ping"}, WsFuncData{Payload: MessagePayload{Event: "ping"}})
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if last := lines[len(lines)-1]; !strings.Contains(last, " app ") {
+		t.Fatalf("entry %q of an event outside the group, want the module app", last)
+	}
+}
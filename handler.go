@@ -2,11 +2,15 @@ package websockethandler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"runtime/debug"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -14,14 +18,18 @@ import (
 type HandlerFunc func(context.Context, WsFuncData) (WsFuncData, error)
 
 type wsHandlerTree struct {
+	id       handlerID
+	meta     WsFunc
 	main     HandlerFunc
 	parent   *wsHandlerTree
-	children *wsHandlerTree
+	children []*wsHandlerTree
 }
 
 type WsFuncData struct {
 	Client  interface{}
 	Payload MessagePayload
+	// Binary data carried out-of-band by the transport, not a part of the JSON
+	Attachments map[string][]byte `json:"-"`
 }
 
 // MessagePayload represents the structure of incoming WebSocket messages
@@ -29,44 +37,284 @@ type MessagePayload struct {
 	Event     string      `json:"event"`
 	Data      interface{} `json:"data,omitempty"`
 	Status    string      `json:"status,omitempty"`
-	Broadcast bool        `json:"-"`
+	Warnings  []string    `json:"warnings,omitempty"`
+	ElapsedMs float64     `json:"elapsed_ms,omitempty"`
+	Code      ErrorCode   `json:"code,omitempty"`
+	// Correlation id of the request, preserved in all outputs of the call
+	RequestID string `json:"id,omitempty"`
+	Broadcast bool   `json:"-"`
+	// Client the payload is delivered to by the transport, nil means the sender
+	TargetClient interface{} `json:"-"`
 }
 
+// Addressing the response to another client instead of the sender,
+// the delivery is done by the transport
+func SendTo(data *WsFuncData, client interface{}) {
+	data.Payload.TargetClient = client
+}
+
+// Attaching a non-fatal warning to the response,
+// the status of the response is not changed
+func AddWarning(data *WsFuncData, msg string) {
+	data.Payload.Warnings = append(data.Payload.Warnings, msg)
+}
+
+// Key of the registration. A function registered with the empty Status
+// is called for any Status of the event that has no exact registration
 type WsFunc struct {
 	Event  string
 	Status string
 }
 
+// Readable form of the meta: "event" or "event#status"
+func (m WsFunc) String() string {
+	if m.Status == "" {
+		return m.Event
+	}
+	return m.Event + "#" + m.Status
+}
+
 // Handler for functions
 // Must support automatic error logging
 // And message return to the user in the channel
 type WsHandler interface {
 	Handle(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) WsHandler
+	HandleValidated(meta WsFunc, f HandlerFunc, v Validator, parent ...HandlerFunc) WsHandler
+	HandleE(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) error
+	Replace(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) WsHandler
+	HandleAll(regs []Registration) WsHandler
+	RegisterAll(regs []Registration) []error
+	Deregister(meta WsFunc) WsHandler
+	DeregisterCascade(meta WsFunc) WsHandler
+	IsRegistered(meta WsFunc) bool
+	RegisteredEvents() []WsFunc
+	PipelineChain(meta WsFunc) ([]WsFunc, error)
+	Pipelines() map[WsFunc][]WsFunc
+	SetGoroutineBudget(n int) WsHandler
+	HandlePipelineScope(rootMeta WsFunc, setup func(ctx context.Context) (context.Context, error), teardown func(ctx context.Context, err error)) WsHandler
+	Stats() HandlerStats
+	SetConcurrencyLimit(meta WsFunc, n int) WsHandler
+	SetMetricsSink(sink MetricsSink) WsHandler
+	RegisterClient(id string, ch chan MessagePayload) WsHandler
+	UnregisterClient(id string) WsHandler
+	OnConnect(f func(client interface{})) WsHandler
+	OnDisconnect(f func(client interface{})) WsHandler
+	NotifyConnect(client interface{})
+	NotifyDisconnect(client interface{})
+	SetBroadcastTimeout(d time.Duration) WsHandler
+	HandleMulti(meta WsFunc, f MultiHandlerFunc) WsHandler
+	HandlePinned(meta WsFunc, f HandlerFunc) WsHandler
+	HandleStream(meta WsFunc, f StreamHandlerFunc) WsHandler
 	CallFunc(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error)
+	CallFuncDryRun(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error)
+	CallMulti(ctx context.Context, meta WsFunc, data WsFuncData) ([]WsFuncData, error)
 	CallPipelineFunc(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload) error
+	HandleRaw(ctx context.Context, client interface{}, raw []byte) (WsFuncData, error)
+	CallStreaming(ctx context.Context, meta WsFunc, data WsFuncData) <-chan MessagePayload
+	CallPipelineBatched(ctx context.Context, meta WsFunc, data WsFuncData, flush time.Duration) <-chan []MessagePayload
+	CallPipelineFuncSync(ctx context.Context, meta WsFunc, data WsFuncData) ([]MessagePayload, error)
+	SetBranchStrategy(strategy BranchStrategy) WsHandler
+	CallPipelineFuncWithOptions(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload, opts PipelineOptions) error
+	CallPipelineFuncParallel(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload) error
 	AddLogger(logger stdLogger) WsHandler
 	SetLogLevel(level string) WsHandler
+	Level() string
+	SetLoggerMinLevel(level string) WsHandler
+	SetLogSource(enabled bool) WsHandler
+	SetModuleName(name string) WsHandler
+	SetLogMaxBodyBytes(n int) WsHandler
+	SetLogSampling(n int) WsHandler
+	SetFatalBehavior(behavior FatalBehavior) WsHandler
+	Shutdown(ctx context.Context) error
 	GetError() error
+	ClearError() WsHandler
+	Cancel(key string) bool
+	CancelWithReason(key string, reason string) bool
+	CancelPipeline(id string) bool
+	SetClientQuota(max int, window time.Duration, keyFunc func(WsFuncData) string) WsHandler
+	SetRateLimit(eventsPerSec float64, burst int) WsHandler
+	SetCodec(c Codec) WsHandler
+	Codec() Codec
+	SetClientIdentity(f func(client interface{}) string) WsHandler
+	ExportGraph() ([]byte, error)
+	SetDefaultHandler(f HandlerFunc) WsHandler
+	SetDispatcher(d Dispatcher) WsHandler
+	DefaultDispatcher() Dispatcher
+	LastError(meta WsFunc) (error, time.Time, bool)
+	EnableMemoize(meta WsFunc, maxEntries int) WsHandler
+	ClearMemoize(meta WsFunc) WsHandler
+	MetricsSnapshot() MetricsState
+	SetReady(meta WsFunc, ready bool) WsHandler
+	Ready(meta WsFunc) bool
+	SetTestMode(enabled bool) WsHandler
+	SetReportTiming(enabled bool) WsHandler
+	SetStreamBufferSize(n int) WsHandler
+	SetResponseTransformer(t ResponseTransformer) WsHandler
+	NoTransform(meta WsFunc) WsHandler
+	AddEventAlias(oldEvent, newEvent string) WsHandler
+	SetEventNormalizer(f func(string) string) WsHandler
+	SetPipelineErrorMode(mode PipelineErrorMode) WsHandler
+	SetDefaultPipeline(stages ...HandlerFunc) WsHandler
+	SetPipelineBacklogLimit(n int) WsHandler
+	SetPipelineBacklogPolicy(policy BacklogPolicy) WsHandler
+	DroppedPayloads() uint64
+	PipelineBacklogPeak() int
+	SetPanicHandler(f PanicHandler) WsHandler
+	OnError(f func(meta WsFunc, in WsFuncData, err error)) WsHandler
+	SetAccessLogger(f func(AccessLogEntry)) WsHandler
+	SetContextDecorator(f func(ctx context.Context, data WsFuncData) context.Context) WsHandler
+	SetDeadLetter(f func(meta WsFunc, data WsFuncData, err error)) WsHandler
+	SetErrorFormatter(f func(meta WsFunc, data WsFuncData, err error) MessagePayload) WsHandler
+	Replay(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error)
+	Use(mw Middleware) WsHandler
+	UseFirst(mw Middleware) WsHandler
+	UseLast(mw Middleware) WsHandler
+	UseFor(meta WsFunc, mw Middleware) WsHandler
+	Group(prefix string) HandlerGroup
+	MustBeUnique() WsHandler
+	PanicOnError() WsHandler
+	SetEmptyOutputPolicy(policy EmptyOutputPolicy) WsHandler
+	Clone() WsHandler
 }
 
 type wsHandler struct {
 	mutex    sync.RWMutex
 	fun      map[WsFunc]HandlerFunc
-	funcTree map[string]*wsHandlerTree
+	funcTree map[handlerID]*wsHandlerTree
+	funcIDs  map[funcKey]handlerID
+	lastID   handlerID
+	multi    map[WsFunc]MultiHandlerFunc
+	streams  map[WsFunc]StreamHandlerFunc
+	// Stop funcs of the workers of the pinned functions
+	pinned map[WsFunc]func()
+
+	// Metas registered more than once
+	duplicates []WsFunc
+
+	// Logging, guarded by its own lock, so that log entries may be written
+	// with or without the lock of the handler
+	logMutex      sync.RWMutex
+	logger        stdLogger
+	logLevel      level
+	loggerLevel   level
+	logSource     bool
+	module        string
+	groupModules  map[string]string // module names of the groups by prefix
+	logMaxBody    int
+	sampler       *logSampler
+	fatalBehavior FatalBehavior
+	now           func() time.Time // clock of the log entries and the durations, see WithClock
+	err           error
+
+	// Limits
+	quota          *clientQuota
+	rateLimit      *rateLimiter
+	clientIdentity func(client interface{}) string
+
+	// Serialization of the connection layer
+	codec Codec
+
+	emptyOutput  EmptyOutputPolicy
+	dispatcher   Dispatcher
+	testMode     bool
+	reportTiming bool
+
+	streamBufferSize int
+
+	memo map[WsFunc]*memoCache
 
-	// Logging
-	logger   stdLogger
-	logLevel level
-	err      error
+	transformer ResponseTransformer
+	noTransform map[WsFunc]struct{}
+
+	aliases    map[string]string
+	normalizer func(string) string
+
+	pipelineErrorMode PipelineErrorMode
+	branchStrategy    BranchStrategy
+	pipelineTimeout   time.Duration
+	backlogLimit      int
+	backlogPolicy     BacklogPolicy
+	droppedPayloads   uint64
+	backlogPeak       int64
+	goroutines        int64
+	budget            chan struct{}
+	workers           *workerPool
+
+	defaultPipeline *wsHandlerTree
+	defaultHandler  HandlerFunc
+
+	panicHandler PanicHandler
+	onError      func(meta WsFunc, in WsFuncData, err error)
+	decorators   []func(ctx context.Context, data WsFuncData) context.Context
+	accessLogger func(AccessLogEntry)
+	deadLetter   func(meta WsFunc, data WsFuncData, err error)
+	errorFormat  func(meta WsFunc, data WsFuncData, err error) MessagePayload
+	onConnect    func(client interface{})
+	onDisconnect func(client interface{})
+
+	middlewareFirst []Middleware
+	middleware      []Middleware
+	middlewareLast  []Middleware
+	eventMiddleware map[WsFunc][]Middleware
+	groups          map[WsFunc]*wsHandlerGroup
+	scopes          map[WsFunc]pipelineScope
+	retries         map[WsFunc]retryPolicy
+	validators      map[WsFunc]Validator
+	limits          map[WsFunc]chan struct{}
+
+	metricsSink MetricsSink
+	lastErrors  lastErrors
+	readiness   readiness
+	metrics     metrics
+	clients     clients
+
+	// Cancellation of active calls
+	shutdown shutdown
+
+	cancelMutex sync.Mutex
+	cancels     map[string]map[*trackedCall]struct{}
+	// Active pipelines by request id
+	pipelines map[string]map[*trackedCall]struct{}
 }
 
-func NewHandler() WsHandler {
+func NewHandler(opts ...Option) WsHandler {
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
 	handler := &wsHandler{
-		fun:      make(map[WsFunc]HandlerFunc),
-		funcTree: make(map[string]*wsHandlerTree),
-		logger:   logger,
-		logLevel: infoLevel,
+		fun:         make(map[WsFunc]HandlerFunc),
+		funcTree:    make(map[handlerID]*wsHandlerTree),
+		funcIDs:     make(map[funcKey]handlerID),
+		multi:       make(map[WsFunc]MultiHandlerFunc),
+		streams:     make(map[WsFunc]StreamHandlerFunc),
+		pinned:      make(map[WsFunc]func()),
+		noTransform: make(map[WsFunc]struct{}),
+		memo:        make(map[WsFunc]*memoCache),
+		aliases:     make(map[string]string),
+
+		eventMiddleware: make(map[WsFunc][]Middleware),
+		groups:          make(map[WsFunc]*wsHandlerGroup),
+		scopes:          make(map[WsFunc]pipelineScope),
+		retries:         make(map[WsFunc]retryPolicy),
+		validators:      make(map[WsFunc]Validator),
+		limits:          make(map[WsFunc]chan struct{}),
+		cancels:         make(map[string]map[*trackedCall]struct{}),
+		pipelines:       make(map[string]map[*trackedCall]struct{}),
+		lastErrors:      lastErrors{errs: make(map[WsFunc]lastError)},
+		metrics:         metrics{events: make(map[WsFunc]EventMetrics)},
+		readiness:       readiness{notReady: make(map[WsFunc]struct{})},
+		clients:         clients{chans: make(map[string]chan MessagePayload)},
+		logger:          logger,
+		logLevel:        infoLevel,
+		loggerLevel:     traceLevel,
+		module:          defaultModuleName,
+		groupModules:    make(map[string]string),
+		now:             time.Now,
+		codec:           JSONCodec{},
+
+		streamBufferSize: defaultStreamBufferSize,
+		pipelineTimeout:  defaultPipelineTimeout,
+	}
+	for _, opt := range opts {
+		opt(handler)
 	}
 	handler.log(
 		infoLevel,
@@ -75,30 +323,113 @@ func NewHandler() WsHandler {
 	return handler
 }
 
+// Creating the handler with the logger, an unusable logger is reported
+// by the error instead of a panic on the first log entry
+func NewHandlerSafe(logger stdLogger) (WsHandler, error) {
+	if err := validateLogger(logger); err != nil {
+		return nil, fmt.Errorf("%w:%s", err, getFunctionName())
+	}
+	handler := NewHandler().AddLogger(logger)
+	return handler, handler.GetError()
+}
+
 func (h *wsHandler) log(lvl level, event error, data ...interface{}) {
-	if h.logLevel >= lvl {
-		logMsg := strLog{
-			UUID:   uuid.NewString(),
-			Event:  fmt.Errorf("%w", event),
-			Level:  lvl,
-			Module: "websockethandler",
-			Body:   data,
+	h.logMutex.RLock()
+	defer h.logMutex.RUnlock()
+	if h.logLevel >= lvl && h.loggerLevel >= lvl {
+		if h.sampler != nil {
+			emit, summaries := h.sampler.sample(lvl, event.Error())
+			for _, summary := range summaries {
+				h.print(summary.level, fmt.Errorf("suppressed %d similar log entries:%s", summary.count, summary.event))
+			}
+			if !emit {
+				return
+			}
+		}
+		h.print(lvl, event, data...)
+	}
+}
+
+func (h *wsHandler) print(lvl level, event error, data ...interface{}) {
+	logMsg := strLog{
+		UUID:      uuid.NewString(),
+		Time:      h.now(),
+		RequestID: requestIDOf(data),
+		Event:     fmt.Errorf("%w", event),
+		Level:     lvl,
+		Module:    h.moduleOf(data),
+		Body:      h.logBody(data),
+	}
+	if h.logSource {
+		_, logMsg.File, logMsg.Line, _ = runtime.Caller(2)
+	}
+	if lvl <= fatalLevel {
+		switch h.fatalBehavior {
+		case BehaviorExit:
+			h.logger.Fatal(logMsg)
+			return
+		case BehaviorPanic:
+			h.logger.Panic(logMsg)
+			return
 		}
-		h.logger.Print(logMsg)
 	}
+	h.logger.Print(logMsg)
 }
 
+// Truncating the serialized body of the log entry to logMaxBody bytes,
+// the cut is moved back to the rune boundary
+func (h *wsHandler) logBody(data []interface{}) interface{} {
+	if h.logMaxBody <= 0 {
+		return data
+	}
+	body := fmt.Sprintf("%v", data)
+	if len(body) > h.logMaxBody {
+		n := h.logMaxBody
+		for n > 0 && !utf8.RuneStart(body[n]) {
+			n--
+		}
+		return body[:n] + "...(truncated)"
+	}
+	return body
+}
+
+// Latched error of the handler.
+// While it is set, the Handle* methods and the setters returning WsHandler do nothing.
+// The calls, RegisterAll, HandleE and the runtime state methods such as SetReady,
+// Cancel or UnregisterClient do not respect the latch
 func (h *wsHandler) GetError() error {
 	return h.err
 }
 
+// Resetting the latched error, e.g. to go on after a failed dynamic registration.
+// The duplicates recorded for MustBeUnique are kept
+func (h *wsHandler) ClearError() WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = nil
+	return h
+}
+
 func (h *wsHandler) AddLogger(logger stdLogger) WsHandler {
 	if h.err == nil {
+		if err := validateLogger(logger); err != nil {
+			h.err = fmt.Errorf("%w:%s", err, "AddLogger")
+			return h
+		}
+		h.logMutex.Lock()
+		defer h.logMutex.Unlock()
 		h.logger = logger
 	}
 	return h
 }
 
+// Current logging level, SetLogLevel accepts it back
+func (h *wsHandler) Level() string {
+	h.logMutex.RLock()
+	defer h.logMutex.RUnlock()
+	return h.logLevel.String()
+}
+
 // Setting the logging level
 func (h *wsHandler) SetLogLevel(level string) WsHandler {
 	if h.err == nil {
@@ -106,7 +437,9 @@ func (h *wsHandler) SetLogLevel(level string) WsHandler {
 		if err != nil {
 			h.err = fmt.Errorf("%w:%s", err, "SetLogLevel")
 		} else {
+			h.logMutex.Lock()
 			h.logLevel = lvl
+			h.logMutex.Unlock()
 			h.log(infoLevel,
 				fmt.Errorf("change log level to %s", level))
 		}
@@ -114,144 +447,638 @@ func (h *wsHandler) SetLogLevel(level string) WsHandler {
 	return h
 }
 
-// Function registration
-func (h *wsHandler) Handle(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) WsHandler {
+// Setting the level of entries passed to the logger,
+// applied in addition to the logging level
+func (h *wsHandler) SetLoggerMinLevel(level string) WsHandler {
+	if h.err == nil {
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			h.err = fmt.Errorf("%w:%s", err, "SetLoggerMinLevel")
+		} else {
+			h.logMutex.Lock()
+			h.loggerLevel = lvl
+			h.logMutex.Unlock()
+			h.log(infoLevel,
+				fmt.Errorf("change logger min level to %s", level))
+		}
+	}
+	return h
+}
+
+// Adding the file and line of the logging call to log entries
+func (h *wsHandler) SetLogSource(enabled bool) WsHandler {
+	if h.err == nil {
+		h.logMutex.Lock()
+		defer h.logMutex.Unlock()
+		h.logSource = enabled
+	}
+	return h
+}
+
+// Setting the module name of log entries, an empty name restores the package name
+func (h *wsHandler) SetModuleName(name string) WsHandler {
+	if h.err == nil {
+		if name == "" {
+			name = defaultModuleName
+		}
+		h.logMutex.Lock()
+		defer h.logMutex.Unlock()
+		h.module = name
+	}
+	return h
+}
+
+// Setting the max size of the log entry body, 0 disables the truncation
+func (h *wsHandler) SetLogMaxBodyBytes(n int) WsHandler {
+	if h.err == nil {
+		if n < 0 {
+			h.err = fmt.Errorf("not a valid log body size:%d:%s", n, getFunctionName())
+			return h
+		}
+		h.logMutex.Lock()
+		defer h.logMutex.Unlock()
+		h.logMaxBody = n
+	}
+	return h
+}
+
+// Setting the behavior of the fatal and panic level log entries.
+// Log-only by default, since exiting from a log call in a shared process is rarely wanted
+func (h *wsHandler) SetFatalBehavior(behavior FatalBehavior) WsHandler {
+	if h.err == nil {
+		if behavior > BehaviorPanic {
+			h.err = fmt.Errorf("not a valid fatal behavior:%d:%s", behavior, getFunctionName())
+			return h
+		}
+		h.logMutex.Lock()
+		defer h.logMutex.Unlock()
+		h.fatalBehavior = behavior
+	}
+	return h
+}
+
+// Enabling the test mode: handlers are called synchronously and directly,
+// without the timeout of the pipeline stages and the goroutine in shell.
+// For tests only, a blocked handler is never interrupted in this mode
+func (h *wsHandler) SetTestMode(enabled bool) WsHandler {
 	if h.err == nil {
 		h.mutex.Lock()
 		defer h.mutex.Unlock()
-		if _, ok := h.fun[meta]; ok {
-			h.err = fmt.Errorf("func with current params has been registered")
-		} else {
-			if len(parent) > 0 {
-				parentFunc := parent[0]
-				keyMain := fmt.Sprintf("%#v", f)
-				mainHandlerTree, ok := h.funcTree[keyMain]
-				if ok {
-					if mainHandlerTree.children != nil {
-						h.err = fmt.Errorf("the current function has a child function declaration")
-						return h
-					}
-				} else {
-					mainHandlerTree = &wsHandlerTree{main: f}
-					h.funcTree[keyMain] = mainHandlerTree
-				}
-
-				keyParent := fmt.Sprintf("%#v", parentFunc)
-				if parentHandlerTree, ok := h.funcTree[keyParent]; ok {
-					if parentHandlerTree.children != nil {
-						h.err = fmt.Errorf("the parent function has a child function declaration:%s:%s:%s", keyMain, keyParent, getFunctionName())
-						return h
-					}
-					parentHandlerTree.children = mainHandlerTree
-					mainHandlerTree.parent = parentHandlerTree
-				} else {
-					h.err = fmt.Errorf("there is no registered parent function:%s:%s:%s", keyMain, keyParent, getFunctionName())
-					return h
-				}
-			} else {
-				keyMain := fmt.Sprintf("%#v", f)
-				if _, ok := h.funcTree[keyMain]; ok {
-					h.err = fmt.Errorf("this function is declared:%s:%s", keyMain, getFunctionName())
-					return h
-				} else {
-					h.funcTree[keyMain] = &wsHandlerTree{main: f}
-				}
-			}
-			h.fun[meta] = f
+		h.testMode = enabled
+	}
+	return h
+}
+
+// Reporting the execution time of the handler in responses, timeouts included
+func (h *wsHandler) SetReportTiming(enabled bool) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.reportTiming = enabled
+	}
+	return h
+}
+
+// Function registration
+func (h *wsHandler) Handle(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	meta = h.normalize(meta)
+	if h.err != nil {
+		if h.isRegistered(meta) {
+			h.duplicates = append(h.duplicates, meta)
 		}
+		return h
+	}
+	if err := h.register(meta, f, parent...); err != nil {
+		if errors.Is(err, ErrAlreadyRegistered) {
+			h.duplicates = append(h.duplicates, meta)
+		}
+		h.err = err
 	}
 	return h
 }
 
-// Calling an event in pipeline mode with self-sending information to a buffered channel
+// Registering like Handle and returning the error of this registration,
+// the error state of the handler is neither checked nor changed
+func (h *wsHandler) HandleE(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.register(meta, f, parent...)
+}
+
+// Registration without changing the error state, the handler is not changed on error.
+// Must be called under the write lock
+func (h *wsHandler) register(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) error {
+	meta = h.normalize(meta)
+	if h.isRegistered(meta) {
+		return fmt.Errorf("%w:%s:%s", ErrAlreadyRegistered, meta, getFunctionName())
+	}
+	if len(parent) > 0 {
+		mainHandlerTree, ok := h.nodeOf(f)
+		if ok && len(mainHandlerTree.children) > 0 {
+			return fmt.Errorf("the current function has a child function declaration")
+		}
+
+		if keyOf(parent[0]) == keyOf(f) {
+			return fmt.Errorf("the function can not be its own parent:%s:%s", getHandlerName(f), getFunctionName())
+		}
+		parentHandlerTree, ok := h.nodeOf(parent[0])
+		if !ok {
+			return fmt.Errorf("%w:%s:%s:%s", ErrNoParent, getHandlerName(f), getHandlerName(parent[0]), getFunctionName())
+		}
+		if mainHandlerTree != nil && mainHandlerTree.parent != nil && mainHandlerTree.parent != parentHandlerTree {
+			return fmt.Errorf("the function has another parent function:%s:%s:%s", getHandlerName(f), getHandlerName(parent[0]), getFunctionName())
+		}
+		if mainHandlerTree == nil {
+			mainHandlerTree = &wsHandlerTree{meta: meta, main: f}
+			h.addNode(mainHandlerTree)
+		}
+		if mainHandlerTree.parent == nil {
+			parentHandlerTree.children = append(parentHandlerTree.children, mainHandlerTree)
+		}
+		mainHandlerTree.parent = parentHandlerTree
+	} else {
+		if _, ok := h.nodeOf(f); ok {
+			return fmt.Errorf("this function is declared:%s:%s", getHandlerName(f), getFunctionName())
+		}
+		h.addNode(&wsHandlerTree{meta: meta, main: f})
+	}
+	h.fun[meta] = f
+	return nil
+}
+
+// Tree node of the function, must be called under the lock
+func (h *wsHandler) nodeOf(f HandlerFunc) (*wsHandlerTree, bool) {
+	node, ok := h.funcTree[h.funcIDs[keyOf(f)]]
+	return node, ok
+}
+
+// Adding the node to the tree under a new id, must be called under the write lock
+func (h *wsHandler) addNode(node *wsHandlerTree) {
+	h.lastID++
+	node.id = h.lastID
+	h.funcTree[node.id] = node
+	h.funcIDs[keyOf(node.main)] = node.id
+}
+
+// Must be called under the write lock
+func (h *wsHandler) removeNode(node *wsHandlerTree) {
+	delete(h.funcTree, node.id)
+	delete(h.funcIDs, keyOf(node.main))
+}
+
+// Calling an event in pipeline mode with self-sending information to a buffered channel.
+// The error of the failed stage is returned with its event and status,
+// in ModeCollect the errors of all failed stages are joined
 func (h *wsHandler) CallPipelineFunc(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload) error {
+	return h.callPipeline(ctx, meta, data, ch, PipelineOptions{})
+}
+
+// Calling an event in pipeline mode and returning the payloads of all stages
+// in the order they were sent, instead of writing them to a channel
+func (h *wsHandler) CallPipelineFuncSync(ctx context.Context, meta WsFunc, data WsFuncData) ([]MessagePayload, error) {
+	ch := make(chan MessagePayload)
+	collected := make(chan []MessagePayload)
+	go func() {
+		var payloads []MessagePayload
+		for p := range ch {
+			payloads = append(payloads, p)
+		}
+		collected <- payloads
+	}()
+	err := h.CallPipelineFunc(ctx, meta, data, ch)
+	close(ch)
+	return <-collected, err
+}
+
+func (h *wsHandler) callPipeline(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload, opts PipelineOptions) (err error) {
+	data = withRequestID(data)
+	start := h.now()
+	defer func() {
+		status := ""
+		if err != nil {
+			status = ErrorLevel
+		}
+		h.accessLog(start, meta, data, status, err)
+	}()
+	if err := h.enter(meta); err != nil {
+		ch <- h.shutdownPayload(meta, data, err)
+		return err
+	}
+	defer h.leave()
+	ctx, release := h.trackCancel(ctx)
+	defer release()
+	ctx, untrack := h.trackPipeline(ctx, data.Payload.RequestID)
+	defer untrack()
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
+	meta = h.resolveAlias(meta)
+	ctx = h.decorate(ctx, data)
 	h.log(
 		debugLevel,
-		fmt.Errorf("in:%v:%v:%s", meta, data, getFunctionName()),
+		fmt.Errorf("in:%s:%v:%s", meta, data, getFunctionName()),
 	)
+	if payload, err := h.admit(meta, data); err != nil {
+		ch <- payload
+		return err
+	}
 	if f, ok := h.fun[meta]; ok {
-		keyMain := fmt.Sprintf("%#v", f)
-		if f, ok := h.funcTree[keyMain]; ok {
-			for {
-				ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Second*30)
-				defer cancel()
-
-				d := h.shell(f.main, ctxWithTimeout, data)
-				ch <- d.Payload
-				if d.Payload.Status == ErrorLevel {
-					break
-				}
-
-				if f.children != nil {
-					f = f.children
-				} else {
-					break
-				}
-			}
+		if f, ok := h.nodeOf(f); ok {
+			return h.runScoped(ctx, meta, f, data, ch, opts)
 		} else {
-			ch <- MessagePayload{Event: data.Payload.Event, Status: ErrorLevel}
-			return fmt.Errorf("func with current params has not been registered for pipeline:%v:%s", meta, getFunctionName())
+			d, err := h.notRegistered(meta, data)
+			ch <- d.Payload
+			return err
 		}
+	} else if h.defaultPipeline != nil {
+		return h.runPipeline(ctx, h.defaultPipeline, data, ch, opts)
+	} else if h.defaultHandler != nil {
+		return h.runPipeline(ctx, &wsHandlerTree{main: h.defaultHandler}, data, ch, opts)
 	} else {
-		ch <- MessagePayload{Event: data.Payload.Event, Status: ErrorLevel}
-		return fmt.Errorf("func with current params has not been registered:%v:%s", meta, getFunctionName())
+		d, err := h.notRegistered(meta, data)
+		ch <- d.Payload
+		return err
 	}
-	return nil
+}
+
+// Running the stages from the node to the end of the chain
+func (h *wsHandler) runPipeline(ctx context.Context, f *wsHandlerTree, data WsFuncData, ch chan MessagePayload, opts PipelineOptions) error {
+	err, _ := h.runStages(ctx, f, data, ch, opts)
+	return err
+}
+
+// The same as runPipeline, also returning the error of the first failed stage
+func (h *wsHandler) runStages(ctx context.Context, f *wsHandlerTree, data WsFuncData, ch chan MessagePayload, opts PipelineOptions) (err, failed error) {
+	run := &pipelineRun{ctx: ctx, ch: ch, opts: opts, total: chainLength(f)}
+	h.runNode(run, f, data, 0)
+	if len(run.errs) > 0 {
+		return errors.Join(run.errs...), run.failed
+	}
+	// In the fail-fast mode the pipeline has stopped on the failed stage
+	return run.failed, run.failed
+}
+
+// State of one pipeline call shared by all its stages
+type pipelineRun struct {
+	ctx   context.Context
+	ch    chan MessagePayload
+	opts  PipelineOptions
+	total int
+
+	// Guarding the errors, parallel children fail concurrently
+	mutex   sync.Mutex
+	errs    []error
+	failed  error
+	stopped bool
+}
+
+// Recording the error of the failed stage.
+// Returns false when the pipeline stops on it
+func (run *pipelineRun) fail(err error, collect bool) bool {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.failed == nil {
+		run.failed = err
+	}
+	if !collect {
+		run.stopped = true
+		return false
+	}
+	run.errs = append(run.errs, err)
+	return true
+}
+
+func (run *pipelineRun) isStopped() bool {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	return run.stopped
+}
+
+// Running the stage and then the children selected by the branch strategy,
+// depth-first. Returns false when the pipeline stops on the failed stage
+func (h *wsHandler) runNode(run *pipelineRun, f *wsHandlerTree, data WsFuncData, index int) bool {
+	if run.isStopped() {
+		return false
+	}
+	if err := run.ctx.Err(); err != nil {
+		// The cancelled pipeline stops in any mode
+		run.fail(fmt.Errorf("%w:%s", err, f.meta), false)
+		return false
+	}
+	if run.opts.OnStage != nil {
+		h.unlocked(func() { run.opts.OnStage(index, run.total, f.meta) })
+	}
+	stageCtx, cancel := withTimeout(withStagePosition(run.ctx, index, run.total), h.callTimeout(run.ctx, true))
+
+	d, err := h.shell(f.main, stageCtx, f.meta, data)
+	cancel()
+	cfg := h.config()
+	h.unlocked(func() {
+		if d.Payload.Broadcast {
+			h.broadcast(d.Payload)
+		} else {
+			h.send(cfg, run.ctx, run.ch, d.Payload)
+		}
+	})
+	data.Attachments = mergeAttachments(data.Attachments, d.Attachments)
+	if err != nil || d.Payload.Status == ErrorLevel {
+		if err == nil {
+			err = fmt.Errorf("stage returned the error status")
+		}
+		err = fmt.Errorf("%w:%s", err, f.meta)
+		if !run.fail(err, h.pipelineErrorMode == ModeCollect || run.opts.ContinueOnError) {
+			return false
+		}
+	}
+	return h.runChildren(run, h.selectChildren(f, d.Payload), data, index+1)
 }
 
 func (h *wsHandler) CallFunc(ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	out, err := h.call(ctx, meta, data, h.dispatchFunc)
+	return out[0], err
+}
+
+// The outputs of the call of the resolved meta, called under the read lock
+type callDispatch func(ctx context.Context, meta WsFunc, data WsFuncData) ([]WsFuncData, error)
+
+// Entry shared by CallFunc and CallMulti: the request id, the access log
+// and the broadcast of the outputs marked by the handler
+func (h *wsHandler) call(ctx context.Context, meta WsFunc, data WsFuncData, dispatch callDispatch) ([]WsFuncData, error) {
+	data = withRequestID(data)
+	start := h.now()
+	out, err := h.callFunc(ctx, meta, data, dispatch)
+	for _, d := range out {
+		if d.Payload.Broadcast {
+			h.broadcast(d.Payload)
+		}
+	}
+	status := ""
+	if len(out) > 0 {
+		status = out[0].Payload.Status
+	}
+	h.accessLog(start, meta, data, status, err)
+	return out, err
+}
+
+// The shutdown, cancel and timeout of the call, the alias, the decorators
+// and the entry checks before the dispatch. A failed call has one error output
+func (h *wsHandler) callFunc(ctx context.Context, meta WsFunc, data WsFuncData, dispatch callDispatch) ([]WsFuncData, error) {
+	if err := h.enter(meta); err != nil {
+		return []WsFuncData{{Client: data.Client, Payload: h.shutdownPayload(meta, data, err)}}, err
+	}
+	defer h.leave()
+	ctx, release := h.trackCancel(ctx)
+	defer release()
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
+	ctx, cancel := withTimeout(ctx, h.callTimeout(ctx, false))
+	defer cancel()
+	meta = h.resolveAlias(meta)
+	ctx = h.decorate(ctx, data)
 	h.log(
 		debugLevel,
-		fmt.Errorf("in:%v:%v:%s", meta, data, getFunctionName()),
+		fmt.Errorf("in:%s:%v:%s", meta, data, getFunctionName()),
 	)
-	if f, ok := h.fun[meta]; ok {
-		d := h.shell(f, ctx, data)
-		h.log(
-			debugLevel,
-			fmt.Errorf("out:%v:%v:%s", meta, d, getFunctionName()),
-		)
-		return d, nil
+	if payload, err := h.admit(meta, data); err != nil {
+		return []WsFuncData{{Client: data.Client, Payload: payload}}, err
+	}
+	out, err := dispatch(ctx, meta, data)
+	h.log(
+		debugLevel,
+		fmt.Errorf("out:%s:%v:%s", meta, out, getFunctionName()),
+	)
+	return out, err
+}
+
+// Dispatch of CallFunc by the custom dispatcher or the default one
+func (h *wsHandler) dispatchFunc(ctx context.Context, meta WsFunc, data WsFuncData) ([]WsFuncData, error) {
+	var d WsFuncData
+	var err error
+	if dispatcher := h.dispatcher; dispatcher != nil {
+		h.unlocked(func() {
+			d, err = dispatcher.Dispatch(ctx, meta, data)
+		})
 	} else {
-		return WsFuncData{Payload: MessagePayload{Event: data.Payload.Event, Status: ErrorLevel}},
-			fmt.Errorf("func with current params has not been registered:%v:%s", meta, getFunctionName())
-	}
-}
-
-func (h *wsHandler) shell(f HandlerFunc, ctx context.Context, data WsFuncData) WsFuncData {
-	for {
-		select {
-		case <-ctx.Done():
-			if ctx.Err() == context.DeadlineExceeded {
-				h.log(
-					errorLevel,
-					fmt.Errorf("%w:%s", ctx.Err(), getFunctionName()),
-					data.Payload,
-					data.Client,
-				)
-				return WsFuncData{
-					Client: data.Client,
-					Payload: MessagePayload{
-						Event:  data.Payload.Event,
-						Status: ErrorLevel,
-						Data:   "timeout reached",
-					},
-				}
+		d, err = h.dispatch(ctx, meta, data)
+	}
+	return []WsFuncData{d}, err
+}
+
+// Checks of the client and the event at the entry of every call:
+// the quota, the rate limit and the readiness. Returns the error payload
+// and the error of the first failed check, must be called under the read lock
+func (h *wsHandler) admit(meta WsFunc, data WsFuncData) (MessagePayload, error) {
+	var err error
+	switch {
+	case h.quota != nil && !h.quota.allow(data):
+		err = ErrQuotaExceeded
+	case !h.rateAllowed(data):
+		err = ErrRateLimited
+	case !h.ready(meta):
+		err = ErrNotReady
+	default:
+		return MessagePayload{}, nil
+	}
+	payload := MessagePayload{Event: data.Payload.Event, RequestID: data.Payload.RequestID, Status: ErrorLevel, Data: err.Error()}
+	err = fmt.Errorf("%w:%s:%s", err, meta, getFunctionName())
+	return h.formatError(meta, data, err, payload), err
+}
+
+// Running the handler, the returned error is the error of the handler
+// or of the context if the handler has not been completed.
+// Must be called under the read lock, the handler and the hooks run without it
+func (h *wsHandler) shell(f HandlerFunc, ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	cfg := h.config()
+	transformer := h.transformerOf(meta)
+	if v, ok := h.validators[meta]; ok {
+		if err := v(data); err != nil {
+			d, err := h.rejected(meta, data, err)
+			h.unlocked(func() {
+				d = transform(transformer, meta, d)
+			})
+			return d, err
+		}
+	}
+	f = h.wrap(meta, h.limited(cfg, meta, h.memoized(meta, f)))
+	retry := h.retries[meta]
+	var d WsFuncData
+	var err error
+	h.unlocked(func() {
+		start := h.now()
+		d, err = h.executeRetrying(cfg, retry, f, ctx, meta, data)
+		elapsed := h.now().Sub(start)
+		h.metrics.observe(meta, elapsed, err)
+		if cfg.metricsSink != nil {
+			cfg.metricsSink.ObserveHandler(meta, elapsed, err)
+		}
+		if cfg.reportTiming {
+			d.Payload.ElapsedMs = float64(elapsed.Microseconds()) / 1000
+		}
+		if err != nil {
+			if cfg.errorFormat != nil {
+				d.Payload = cfg.errorFormat(meta, data, err)
 			}
-		case <-time.After(time.Millisecond):
-			d, err := f(ctx, data)
-			if err != nil {
-				h.log(
-					errorLevel,
-					fmt.Errorf("%w:%s", err, getFunctionName()),
-					data.Payload,
-					data.Client,
-				)
+			if d.Payload.Code == "" {
+				d.Payload.Code = ErrorCodeOf(err)
+			}
+			h.lastErrors.set(meta, err)
+			if cfg.onError != nil {
+				cfg.onError(meta, data, err)
+			}
+			if cfg.deadLetter != nil {
+				cfg.deadLetter(meta, data, err)
 			}
-			return d
 		}
+		if d.Payload.RequestID == "" {
+			d.Payload.RequestID = data.Payload.RequestID
+		}
+		d = transform(transformer, meta, d)
+	})
+	return d, err
+}
+
+// Settings and hooks of the handler read by a call under the lock.
+// The handler runs without the lock, so that a slow handler does not hold back
+// the setters, and it reads the settings only from the copy
+type callConfig struct {
+	testMode     bool
+	reportTiming bool
+	budget       chan struct{}
+	workers      *workerPool
+	emptyOutput  EmptyOutputPolicy
+	panicHandler PanicHandler
+	metricsSink  MetricsSink
+	errorFormat  func(meta WsFunc, data WsFuncData, err error) MessagePayload
+	onError      func(meta WsFunc, in WsFuncData, err error)
+	deadLetter   func(meta WsFunc, data WsFuncData, err error)
+
+	backlogLimit  int
+	backlogPolicy BacklogPolicy
+}
+
+// Must be called under the read lock
+func (h *wsHandler) config() callConfig {
+	return callConfig{
+		testMode:     h.testMode,
+		reportTiming: h.reportTiming,
+		budget:       h.budget,
+		workers:      h.workers,
+		emptyOutput:  h.emptyOutput,
+		panicHandler: h.panicHandler,
+		metricsSink:  h.metricsSink,
+		errorFormat:  h.errorFormat,
+		onError:      h.onError,
+		deadLetter:   h.deadLetter,
+
+		backlogLimit:  h.backlogLimit,
+		backlogPolicy: h.backlogPolicy,
+	}
+}
+
+// Running fn with the read lock of the caller released, so that a slow handler
+// or a blocked send does not hold back the registration and the other calls.
+// The lock is taken again before returning, also on panic
+func (h *wsHandler) unlocked(fn func()) {
+	h.mutex.RUnlock()
+	defer h.mutex.RLock()
+	fn()
+}
+
+// Running the handler in a goroutine until it returns or ctx is done.
+// When ctx is done first, the timeout or cancel payload is returned at once,
+// the handler keeps running in the background and its result is discarded
+func (h *wsHandler) execute(cfg callConfig, f HandlerFunc, ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	if cfg.testMode {
+		return h.invoke(cfg, f, ctx, meta, data)
+	}
+	if ctx.Err() != nil {
+		return h.interrupted(ctx, data)
+	}
+	type result struct {
+		data WsFuncData
+		err  error
+	}
+	// Buffered, so that the goroutine of a discarded handler does not leak
+	done := make(chan result, 1)
+	release, err := h.acquire(ctx, meta, cfg)
+	if err != nil {
+		return h.interrupted(ctx, data)
+	}
+	job := func() {
+		defer release()
+		d, err := h.invoke(cfg, f, withWorker(ctx, cfg.workers), meta, data)
+		done <- result{data: d, err: err}
+	}
+	if !h.spawn(cfg, ctx, meta, job) {
+		release()
+		return h.interrupted(ctx, data)
+	}
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return h.interrupted(ctx, data)
+	}
+}
+
+// Payload and error of the call whose context is done before the handler returned.
+// The payload has CodeTimeout or CodeCancelled, so that the client tells it
+// from an error returned by the handler
+func (h *wsHandler) interrupted(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		h.log(
+			errorLevel,
+			fmt.Errorf("%w:%s", ctx.Err(), getFunctionName()),
+			data.Payload,
+			data.Client,
+		)
+		return WsFuncData{
+			Client: data.Client,
+			Payload: MessagePayload{
+				Event:  data.Payload.Event,
+				Status: ErrorLevel,
+				Code:   CodeTimeout,
+				Data:   ErrTimeout.Error(),
+			},
+		}, fmt.Errorf("%w:%w", ErrTimeout, ctx.Err())
+	}
+	msg := "call cancelled"
+	if reason, ok := CancelReasonFromContext(ctx); ok {
+		msg = fmt.Sprintf("%s:%s", msg, reason)
+	}
+	h.log(
+		warnLevel,
+		fmt.Errorf("%s:%s", msg, getFunctionName()),
+		data.Payload,
+		data.Client,
+	)
+	return WsFuncData{
+		Client: data.Client,
+		Payload: MessagePayload{
+			Event:  data.Payload.Event,
+			Status: ErrorLevel,
+			Code:   CodeCancelled,
+			Data:   msg,
+		},
+	}, fmt.Errorf("%w:%s", ctx.Err(), msg)
+}
+
+func (h *wsHandler) invoke(cfg callConfig, f HandlerFunc, ctx context.Context, meta WsFunc, data WsFuncData) (d WsFuncData, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			d, err = h.recovered(cfg, meta, data, PanicInfo{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	d, err = f(ctx, data)
+	if err != nil {
+		h.log(
+			errorLevel,
+			fmt.Errorf("%w:%s", err, getFunctionName()),
+			data.Payload,
+			data.Client,
+		)
+		return d, err
 	}
+	return h.applyEmptyOutputPolicy(cfg.emptyOutput, data, d), nil
 }
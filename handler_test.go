@@ -0,0 +1,336 @@
+package websockethandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Handler writing nothing to the output of the test
+func newTestHandler(t *testing.T, opts ...Option) WsHandler {
+	t.Helper()
+	discard := func(h *wsHandler) { h.logger = log.New(io.Discard, "", 0) }
+	h := NewHandler(append([]Option{discard}, opts...)...)
+	if err := h.GetError(); err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	return h
+}
+
+// Writer of the log output read by the test
+type logBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// Stage of the pipeline appending its name to Data
+func stage(name string) HandlerFunc {
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		trail, _ := data.Payload.Data.(string)
+		data.Payload.Data = trail + name
+		return data, nil
+	}
+}
+
+func TestHandleClosuresOfOneFactoryAreSeparateStages(t *testing.T) {
+	h := newTestHandler(t)
+	parent, child := stage("a"), stage("b")
+	root := WsFunc{Event: "root"}
+	next := WsFunc{Event: "next"}
+	if err := h.HandleE(root, parent); err != nil {
+		t.Fatalf("handle parent: %v", err)
+	}
+	if err := h.HandleE(next, child, parent); err != nil {
+		t.Fatalf("handle child: %v", err)
+	}
+
+	chain, err := h.PipelineChain(root)
+	if err != nil {
+		t.Fatalf("pipeline chain: %v", err)
+	}
+	if len(chain) != 2 || chain[0] != root || chain[1] != next {
+		t.Fatalf("chain = %v, want [%s %s]", chain, root, next)
+	}
+
+	out, err := h.CallPipelineFuncSync(context.Background(), root, WsFuncData{Payload: MessagePayload{Event: root.Event}})
+	if err != nil {
+		t.Fatalf("call pipeline: %v", err)
+	}
+	if len(out) != 2 || out[0].Data != "a" || out[1].Data != "b" {
+		t.Fatalf("outputs = %+v, want Data a and b", out)
+	}
+}
+
+func TestHandleTwoTypedHandlers(t *testing.T) {
+	h := newTestHandler(t)
+	double := TypedHandler(func(ctx context.Context, n int) (int, error) { return 2 * n, nil })
+	negate := TypedHandler(func(ctx context.Context, n int) (int, error) { return -n, nil })
+	if err := h.HandleE(WsFunc{Event: "double"}, double); err != nil {
+		t.Fatalf("handle double: %v", err)
+	}
+	if err := h.HandleE(WsFunc{Event: "negate"}, negate); err != nil {
+		t.Fatalf("handle negate: %v", err)
+	}
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "negate"}, WsFuncData{Payload: MessagePayload{Event: "negate", Data: 3}})
+	if err != nil {
+		t.Fatalf("call negate: %v", err)
+	}
+	var n int
+	if err := out.Payload.DecodeData(&n); err != nil || n != -3 {
+		t.Fatalf("negate(3) = %v, %v, want -3", n, err)
+	}
+}
+
+func TestHandleSameFunctionTwiceIsRejected(t *testing.T) {
+	h := newTestHandler(t)
+	f := stage("a")
+	h.Handle(WsFunc{Event: "first"}, f)
+	if err := h.HandleE(WsFunc{Event: "second"}, f); err == nil {
+		t.Fatal("registering the same function value twice succeeded")
+	}
+}
+
+func TestDeregisterPinnedKeepsOtherPinned(t *testing.T) {
+	h := newTestHandler(t)
+	h.HandlePinned(WsFunc{Event: "first"}, stage("a"))
+	h.HandlePinned(WsFunc{Event: "second"}, stage("b"))
+	h.Deregister(WsFunc{Event: "first"})
+	if err := h.GetError(); err != nil {
+		t.Fatalf("handle pinned: %v", err)
+	}
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "second"}, WsFuncData{Payload: MessagePayload{Event: "second"}})
+	if err != nil || out.Payload.Data != "b" {
+		t.Fatalf("call second = %v, %v, want Data b", out.Payload.Data, err)
+	}
+}
+
+func TestSlowHandlerDoesNotBlockRegistrationAndCalls(t *testing.T) {
+	h := newTestHandler(t)
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	h.Handle(WsFunc{Event: "slow"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		close(entered)
+		<-unblock
+		return data, nil
+	})
+	h.Handle(WsFunc{Event: "fast"}, stage("f"))
+	defer close(unblock)
+	go h.CallFunc(context.Background(), WsFunc{Event: "slow"}, WsFuncData{Payload: MessagePayload{Event: "slow"}})
+	<-entered
+
+	done := make(chan error)
+	go func() {
+		if err := h.HandleE(WsFunc{Event: "late"}, stage("l")); err != nil {
+			done <- err
+			return
+		}
+		_, err := h.CallFunc(context.Background(), WsFunc{Event: "fast"}, WsFuncData{Payload: MessagePayload{Event: "fast"}})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("handle and call during the slow handler: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Handle or CallFunc blocked by the slow handler")
+	}
+}
+
+func TestHooksMayCallTheHandler(t *testing.T) {
+	h := newTestHandler(t)
+	failing := WsFunc{Event: "failing"}
+	replayed := make(chan WsFuncData, 1)
+	h.Handle(failing, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, errors.New("failed")
+	})
+	h.Handle(WsFunc{Event: "fallback"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		replayed <- data
+		return data, nil
+	})
+	h.OnError(func(meta WsFunc, in WsFuncData, err error) {
+		h.SetReady(WsFunc{Event: "fallback"}, true)
+	})
+	h.SetDeadLetter(func(meta WsFunc, data WsFuncData, err error) {
+		h.Replay(context.Background(), WsFunc{Event: "fallback"}, data)
+	})
+	h.SetResponseTransformer(func(meta WsFunc, data WsFuncData) WsFuncData {
+		h.Stats()
+		return data
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.CallFunc(context.Background(), failing, WsFuncData{Payload: MessagePayload{Event: failing.Event}})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the call deadlocked on its hooks")
+	}
+	select {
+	case <-replayed:
+	default:
+		t.Fatal("the dead letter was not replayed")
+	}
+}
+
+func TestSettersRaceWithCalls(t *testing.T) {
+	h := newTestHandler(t)
+	h.Handle(WsFunc{Event: "empty"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return WsFuncData{}, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h.CallFunc(context.Background(), WsFunc{Event: "empty"}, WsFuncData{Payload: MessagePayload{Event: "empty"}})
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		h.SetGoroutineBudget(j%3 + 1)
+		h.SetEmptyOutputPolicy(EmptyOutputPolicy(j % 3))
+		h.SetPanicHandler(nil)
+		h.SetMetricsSink(nil)
+		h.SetLogLevel(TraceLevel)
+	}
+	wg.Wait()
+	if err := h.GetError(); err != nil {
+		t.Fatalf("setters: %v", err)
+	}
+}
+
+func TestLogSettersRaceWithCalls(t *testing.T) {
+	h := newTestHandler(t)
+	h.Handle(WsFunc{Event: "failing"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, errors.New("failed")
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h.CallFunc(context.Background(), WsFunc{Event: "failing"}, WsFuncData{Payload: MessagePayload{Event: "failing"}})
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		h.SetLogSampling(j % 3)
+		h.SetLogSource(j%2 == 0)
+		h.SetModuleName("test")
+		h.SetLogMaxBodyBytes(j)
+		h.SetFatalBehavior(BehaviorLog)
+		h.SetTestMode(j%2 == 0)
+	}
+	wg.Wait()
+	if err := h.GetError(); err != nil {
+		t.Fatalf("setters: %v", err)
+	}
+}
+
+func TestLogBodyIsCutOnRuneBoundary(t *testing.T) {
+	h := newTestHandler(t).SetLogMaxBodyBytes(2).(*wsHandler)
+	// "[ж]": the cut at two bytes falls inside the two-byte rune
+	body, _ := h.logBody([]interface{}{"ж"}).(string)
+	if body != "[...(truncated)" {
+		t.Fatalf("body = %q, want the rune dropped", body)
+	}
+	if !utf8.ValidString(body) {
+		t.Fatalf("body %q is not valid UTF-8", body)
+	}
+}
+
+func TestCallFuncReturnsTheHandlerError(t *testing.T) {
+	failure := errors.New("db is down")
+	h := newTestHandler(t).Handle(WsFunc{Event: "failing"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return WsFuncData{Payload: MessagePayload{Event: "failing", Status: ErrorLevel}}, failure
+	})
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "failing"}, WsFuncData{Payload: MessagePayload{Event: "failing"}})
+	if !errors.Is(err, failure) {
+		t.Fatalf("err = %v, want the handler error", err)
+	}
+	if out.Payload.Status != ErrorLevel {
+		t.Fatalf("payload = %+v, want the error payload of the handler", out.Payload)
+	}
+}
+
+func TestCallFuncReturnsTheContextError(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "blocked"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		<-ctx.Done()
+		return data, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out, err := h.CallFunc(ctx, WsFunc{Event: "blocked"}, WsFuncData{Payload: MessagePayload{Event: "blocked"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if out.Payload.Code != CodeCancelled {
+		t.Fatalf("payload = %+v, want CodeCancelled", out.Payload)
+	}
+}
+
+func TestDeregisterDuringPipelineRunsEveryChildOnce(t *testing.T) {
+	h := newTestHandler(t)
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	root := stage("r")
+	h.Handle(WsFunc{Event: "root"}, root)
+	h.Handle(WsFunc{Event: "a"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		close(entered)
+		<-unblock
+		data.Payload.Data = "a"
+		return data, nil
+	}, root)
+	h.Handle(WsFunc{Event: "b"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		data.Payload.Data = "b"
+		return data, nil
+	}, root)
+	h.Handle(WsFunc{Event: "c"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		data.Payload.Data = "c"
+		return data, nil
+	}, root)
+
+	done := make(chan []MessagePayload)
+	go func() {
+		out, _ := h.CallPipelineFuncSync(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}})
+		done <- out
+	}()
+	<-entered
+	h.Deregister(WsFunc{Event: "b"})
+	close(unblock)
+	if err := h.GetError(); err != nil {
+		t.Fatalf("deregister: %v", err)
+	}
+
+	runs := make(map[interface{}]int)
+	for _, p := range <-done {
+		runs[p.Data]++
+	}
+	if runs["a"] != 1 || runs["c"] != 1 || runs["b"] > 1 {
+		t.Fatalf("runs of the stages = %v, want a and c once", runs)
+	}
+}
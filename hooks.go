@@ -0,0 +1,93 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Setting the hook called only for failed handlers: returned errors,
+// timeouts and cancellations with the context error, panics with PanicInfo
+func (h *wsHandler) OnError(f func(meta WsFunc, in WsFuncData, err error)) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.onError = f
+	}
+	return h
+}
+
+// Setting the decorator of the call context, e.g. with the tenant of the client.
+// It runs once at the entry of the call, the decorated context is passed
+// to the middleware and to every pipeline stage, the stage timeout is applied to it.
+// It replaces the decorators added by WithContextDecorator, nil removes all of them
+func (h *wsHandler) SetContextDecorator(f func(ctx context.Context, data WsFuncData) context.Context) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.decorators = nil
+		if f != nil {
+			h.decorators = append(h.decorators, f)
+		}
+	}
+	return h
+}
+
+// Adding the decorator of the call context like SetContextDecorator,
+// the decorators run in the order they are added
+func WithContextDecorator(f func(ctx context.Context, data WsFuncData) context.Context) Option {
+	return func(h *wsHandler) {
+		if f != nil {
+			h.decorators = append(h.decorators, f)
+		}
+	}
+}
+
+// Must be called under the read lock, the decorators run without it
+func (h *wsHandler) decorate(ctx context.Context, data WsFuncData) context.Context {
+	decorators := h.decorators
+	if len(decorators) == 0 {
+		return ctx
+	}
+	h.unlocked(func() {
+		for _, decorator := range decorators {
+			ctx = decorator(ctx, data)
+		}
+	})
+	return ctx
+}
+
+// Setting the formatter of the error payloads sent to the client:
+// the payloads of failed handlers, timeouts and cancellations, rejected input,
+// the quota, rate limit, readiness and shutdown rejections
+// and of events that are not registered. Nil restores the built in payloads
+func (h *wsHandler) SetErrorFormatter(f func(meta WsFunc, data WsFuncData, err error) MessagePayload) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.errorFormat = f
+	}
+	return h
+}
+
+// Error payload built by the package itself, shaped by the error formatter if it is set.
+// Must be called under the read lock, the formatter runs without it
+func (h *wsHandler) errorPayload(meta WsFunc, data WsFuncData, err error) MessagePayload {
+	return h.formatError(meta, data, err, MessagePayload{Event: data.Payload.Event, RequestID: data.Payload.RequestID, Status: ErrorLevel})
+}
+
+// The built in error payload, replaced by the one of the error formatter if it is set.
+// Must be called under the read lock, the formatter runs without it
+func (h *wsHandler) formatError(meta WsFunc, data WsFuncData, err error, payload MessagePayload) MessagePayload {
+	if format := h.errorFormat; format != nil {
+		h.unlocked(func() {
+			payload = format(meta, data, err)
+		})
+	}
+	return payload
+}
+
+// Payload and error of the call for an event that is not registered
+func (h *wsHandler) notRegistered(meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	err := fmt.Errorf("%w:%s:%s", ErrHandlerNotRegistered, meta, getFunctionName())
+	return WsFuncData{Client: data.Client, Payload: h.errorPayload(meta, data, err)}, err
+}
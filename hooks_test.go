@@ -0,0 +1,61 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Formatter hiding the messages of all errors from the client
+func hidden(meta WsFunc, data WsFuncData, err error) MessagePayload {
+	return MessagePayload{Event: data.Payload.Event, Status: ErrorLevel, Data: "request failed"}
+}
+
+func TestErrorFormatterShapesTheRejections(t *testing.T) {
+	ok := WsFunc{Event: "ok"}
+	invalid := errors.New("bad input")
+	tests := []struct {
+		name  string
+		meta  WsFunc
+		setup func(h WsHandler)
+		want  error
+	}{
+		{"not registered", WsFunc{Event: "missing"}, func(h WsHandler) {}, ErrHandlerNotRegistered},
+		{"validator", WsFunc{Event: "validated"}, func(h WsHandler) {
+			h.HandleValidated(WsFunc{Event: "validated"}, stage("v"), func(WsFuncData) error { return invalid })
+		}, invalid},
+		{"quota", ok, func(h WsHandler) {
+			h.SetClientQuota(1, time.Minute, func(WsFuncData) string { return "ann" })
+			h.CallFunc(context.Background(), ok, WsFuncData{Payload: MessagePayload{Event: ok.Event}})
+		}, ErrQuotaExceeded},
+		{"rate limit", ok, func(h WsHandler) {
+			h.SetRateLimit(0.001, 1)
+			h.CallFunc(context.Background(), ok, WsFuncData{Client: "ann", Payload: MessagePayload{Event: ok.Event}})
+		}, ErrRateLimited},
+		{"not ready", ok, func(h WsHandler) { h.SetReady(ok, false) }, ErrNotReady},
+		{"shutdown", ok, func(h WsHandler) { h.Shutdown(context.Background()) }, ErrShuttingDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t).SetErrorFormatter(hidden).Handle(ok, stage("o"))
+			tt.setup(h)
+			out, err := h.CallFunc(context.Background(), tt.meta, WsFuncData{Client: "ann", Payload: MessagePayload{Event: tt.meta.Event}})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if out.Payload.Data != "request failed" {
+				t.Fatalf("payload = %+v, want the payload of the formatter", out.Payload)
+			}
+		})
+	}
+}
+
+func TestErrorFormatterShapesThePipelineShutdown(t *testing.T) {
+	h := newTestHandler(t).SetErrorFormatter(hidden).Handle(WsFunc{Event: "root"}, stage("a"))
+	h.Shutdown(context.Background())
+	out, err := h.CallPipelineFuncSync(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}})
+	if !errors.Is(err, ErrShuttingDown) || len(out) != 1 || out[0].Data != "request failed" {
+		t.Fatalf("pipeline = %+v, %v, want the payload of the formatter", out, err)
+	}
+}
@@ -0,0 +1,108 @@
+package websockethandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Logger writing every entry as one JSON line, for AddLogger
+type JSONLogger struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+type jsonEntry struct {
+	UUID      string      `json:"uuid,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Level     string      `json:"level"`
+	Module    string      `json:"module,omitempty"`
+	Event     string      `json:"event"`
+	Body      interface{} `json:"body,omitempty"`
+	File      string      `json:"file,omitempty"`
+	Line      int         `json:"line,omitempty"`
+	Ts        time.Time   `json:"ts"`
+}
+
+// Creating the logger writing JSON lines to w
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w}
+}
+
+// Writing the entry, values other than the log entry are written as the event
+// at the info level. A body that can not be marshaled is written as a string
+func (l *JSONLogger) write(v ...interface{}) string {
+	entry := jsonEntry{Level: InfoLevel, Ts: time.Now()}
+	if e, ok := singleEntry(v); ok {
+		entry.UUID = e.UUID
+		entry.Ts = e.Time
+		entry.RequestID = e.RequestID
+		entry.Level = e.Level.String()
+		entry.Module = e.Module
+		entry.Event = fmt.Sprint(e.Event)
+		if body, ok := e.Body.([]interface{}); !ok || len(body) > 0 {
+			entry.Body = e.Body
+		}
+		entry.File = e.File
+		entry.Line = e.Line
+	} else {
+		entry.Event = fmt.Sprint(v...)
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		entry.Body = fmt.Sprint(entry.Body)
+		b, _ = json.Marshal(entry)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.w.Write(append(b, '\n'))
+	return entry.Event
+}
+
+func singleEntry(v []interface{}) (strLog, bool) {
+	if len(v) != 1 {
+		return strLog{}, false
+	}
+	e, ok := v[0].(strLog)
+	return e, ok
+}
+
+func (l *JSONLogger) Print(v ...interface{}) {
+	l.write(v...)
+}
+
+func (l *JSONLogger) Printf(format string, v ...interface{}) {
+	l.write(fmt.Sprintf(format, v...))
+}
+
+func (l *JSONLogger) Println(v ...interface{}) {
+	l.write(v...)
+}
+
+func (l *JSONLogger) Fatal(v ...interface{}) {
+	l.write(v...)
+	os.Exit(1)
+}
+
+func (l *JSONLogger) Fatalf(format string, v ...interface{}) {
+	l.Fatal(fmt.Sprintf(format, v...))
+}
+
+func (l *JSONLogger) Fatalln(v ...interface{}) {
+	l.Fatal(v...)
+}
+
+func (l *JSONLogger) Panic(v ...interface{}) {
+	panic(l.write(v...))
+}
+
+func (l *JSONLogger) Panicf(format string, v ...interface{}) {
+	l.Panic(fmt.Sprintf(format, v...))
+}
+
+func (l *JSONLogger) Panicln(v ...interface{}) {
+	l.Panic(v...)
+}
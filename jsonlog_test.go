@@ -0,0 +1,66 @@
+package websockethandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Entries written by the JSON logger, one per line
+func decodeJSONLines(t *testing.T, s string) []jsonEntry {
+	t.Helper()
+	var entries []jsonEntry
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		var entry jsonEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONLoggerWritesTheHandlerEntries(t *testing.T) {
+	logs := &logBuffer{}
+	h := newTestHandler(t).AddLogger(NewJSONLogger(logs)).SetModuleName("orders").
+		Handle(WsFunc{Event: "v2"}, stage("new")).
+		AddEventAlias("v1", "v2")
+	h.CallFunc(context.Background(), WsFunc{Event: "v1"}, WsFuncData{Payload: MessagePayload{Event: "v1"}})
+
+	for _, entry := range decodeJSONLines(t, logs.String()) {
+		if !strings.HasPrefix(entry.Event, "deprecated event alias:v1:v2") {
+			continue
+		}
+		if entry.Level != WarnLevel || entry.Module != "orders" || entry.UUID == "" || entry.Ts.IsZero() {
+			t.Fatalf("entry = %+v, want a warning of the module orders", entry)
+		}
+		return
+	}
+	t.Fatalf("no alias warning in %q", logs.String())
+}
+
+func TestJSONLoggerWritesOtherValuesAsInfo(t *testing.T) {
+	logs := &logBuffer{}
+	NewJSONLogger(logs).Printf("plain %d", 1)
+
+	entries := decodeJSONLines(t, logs.String())
+	if len(entries) != 1 || entries[0].Level != InfoLevel || entries[0].Event != "plain 1" {
+		t.Fatalf("entries = %+v, want one info entry plain 1", entries)
+	}
+}
+
+func TestJSONLoggerWritesAnUnmarshalableBodyAsString(t *testing.T) {
+	logs := &logBuffer{}
+	NewJSONLogger(logs).Print(strLog{Level: errorLevel, Event: "failed", Body: make(chan int)})
+
+	entries := decodeJSONLines(t, logs.String())
+	if len(entries) != 1 || entries[0].Level != ErrorLevel {
+		t.Fatalf("entries = %+v, want one error entry", entries)
+	}
+	if _, ok := entries[0].Body.(string); !ok {
+		t.Fatalf("body = %#v, want a string", entries[0].Body)
+	}
+}
@@ -0,0 +1,37 @@
+package websockethandler
+
+import (
+	"sync"
+	"time"
+)
+
+type lastError struct {
+	err error
+	at  time.Time
+}
+
+// The most recent runtime error of each event.
+// Only registered events get here, so the map is bounded by the registrations
+type lastErrors struct {
+	mutex sync.RWMutex
+	errs  map[WsFunc]lastError
+}
+
+func (l *lastErrors) set(meta WsFunc, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.errs[meta] = lastError{err: err, at: time.Now()}
+}
+
+func (l *lastErrors) get(meta WsFunc) (lastError, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	e, ok := l.errs[meta]
+	return e, ok
+}
+
+// The most recent runtime error of the event and the time it occurred
+func (h *wsHandler) LastError(meta WsFunc) (error, time.Time, bool) {
+	e, ok := h.lastErrors.get(meta)
+	return e.err, e.at, ok
+}
@@ -0,0 +1,51 @@
+package websockethandler
+
+// Client with the id of its broadcast channel, see RegisterClient
+type ClientIdentifier interface {
+	ClientID() string
+}
+
+// Setting the hook called by the connection layer when a client is connected
+func (h *wsHandler) OnConnect(f func(client interface{})) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.onConnect = f
+	}
+	return h
+}
+
+// Setting the hook called by the connection layer when the connection of a client has ended
+func (h *wsHandler) OnDisconnect(f func(client interface{})) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.onDisconnect = f
+	}
+	return h
+}
+
+// Calling the OnConnect hook for the client
+func (h *wsHandler) NotifyConnect(client interface{}) {
+	h.mutex.RLock()
+	f := h.onConnect
+	h.mutex.RUnlock()
+	if f != nil {
+		f(client)
+	}
+}
+
+// Unregistering the broadcast channel of the client and calling the OnDisconnect hook.
+// The id of the channel is the identity of the client, see SetClientIdentity
+func (h *wsHandler) NotifyDisconnect(client interface{}) {
+	h.mutex.RLock()
+	id := h.identify(client)
+	f := h.onDisconnect
+	h.mutex.RUnlock()
+	if id != "" {
+		h.UnregisterClient(id)
+	}
+	if f != nil {
+		f(client)
+	}
+}
@@ -0,0 +1,51 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Setting the maximum number of concurrently running handlers of the event, 0 removes it.
+// A call over the limit waits for a free slot until its ctx is done
+// and then returns the timeout or the cancel payload
+func (h *wsHandler) SetConcurrencyLimit(meta WsFunc, n int) WsHandler {
+	if h.err == nil {
+		if n < 0 {
+			h.err = fmt.Errorf("not a valid concurrency limit:%d:%s", n, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		meta = h.normalize(meta)
+		if n == 0 {
+			delete(h.limits, meta)
+			return h
+		}
+		h.limits[meta] = make(chan struct{}, n)
+	}
+	return h
+}
+
+// Wrapping the handler into the semaphore of the event.
+// The slot is held until the handler returns, even if the call has been interrupted
+func (h *wsHandler) limited(cfg callConfig, meta WsFunc, f HandlerFunc) HandlerFunc {
+	slots, ok := h.limits[meta]
+	if !ok {
+		return f
+	}
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		start := time.Now()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return WsFuncData{
+				Client:  data.Client,
+				Payload: MessagePayload{Event: data.Payload.Event, Status: ErrorLevel},
+			}, ctx.Err()
+		}
+		defer func() { <-slots }()
+		h.observeQueueWait(cfg, meta, time.Since(start))
+		return f(ctx, data)
+	}
+}
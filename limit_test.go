@@ -0,0 +1,76 @@
+package websockethandler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimitCapsTheRunningHandlers(t *testing.T) {
+	meta := WsFunc{Event: "limited"}
+	var running, peak int32
+	h := newTestHandler(t).SetConcurrencyLimit(meta, 2).Handle(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return data, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.CallFunc(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}}); err != nil {
+				t.Errorf("call: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak := atomic.LoadInt32(&peak); peak != 2 {
+		t.Fatalf("peak of the running handlers = %d, want 2", peak)
+	}
+}
+
+func TestConcurrencyLimitWaitEndsWithTheContext(t *testing.T) {
+	meta := WsFunc{Event: "limited"}
+	entered, unblock := make(chan struct{}, 1), make(chan struct{})
+	h := newTestHandler(t).SetConcurrencyLimit(meta, 1).Handle(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		entered <- struct{}{}
+		<-unblock
+		return data, nil
+	})
+	defer close(unblock)
+	go h.CallFunc(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}})
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	out, err := h.CallFunc(ctx, meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}})
+	if err == nil {
+		t.Fatal("the call waiting for a slot returned no error")
+	}
+	if out.Payload.Status != ErrorLevel {
+		t.Fatalf("payload = %+v, want an error payload", out.Payload)
+	}
+	select {
+	case <-entered:
+		t.Fatal("the handler ran over the limit")
+	default:
+	}
+}
+
+func TestConcurrencyLimitRejectsNegative(t *testing.T) {
+	h := newTestHandler(t).SetConcurrencyLimit(WsFunc{Event: "limited"}, -1)
+	if h.GetError() == nil {
+		t.Fatal("a negative limit was accepted")
+	}
+}
@@ -2,7 +2,9 @@ package websockethandler
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 )
 
 type stdLogger interface {
@@ -19,13 +21,34 @@ type stdLogger interface {
 	Panicln(...interface{})
 }
 
+const defaultModuleName = "websockethandler"
+
+// The logger must be a non-nil interface holding a non-nil value
+func validateLogger(logger stdLogger) error {
+	if logger == nil {
+		return fmt.Errorf("logger is nil")
+	}
+	v := reflect.ValueOf(logger)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice:
+		if v.IsNil() {
+			return fmt.Errorf("logger is a nil %s", v.Type())
+		}
+	}
+	return nil
+}
+
 type strLog struct {
-	UUID   string
-	Event  interface{}
-	Level  level
-	Module string
-	Format string
-	Body   interface{}
+	UUID      string
+	Time      time.Time
+	RequestID string
+	Event     interface{}
+	Level     level
+	Module    string
+	Format    string
+	Body      interface{}
+	File      string
+	Line      int
 }
 
 type level uint8
@@ -40,6 +63,39 @@ const (
 	traceLevel
 )
 
+// Canonical name of the level, ParseLevel accepts it back
+func (l level) String() string {
+	switch l {
+	case panicLevel:
+		return PanicLevel
+	case fatalLevel:
+		return FatalLevel
+	case errorLevel:
+		return ErrorLevel
+	case warnLevel:
+		return WarnLevel
+	case infoLevel:
+		return InfoLevel
+	case debugLevel:
+		return DebugLevel
+	case traceLevel:
+		return TraceLevel
+	}
+	return fmt.Sprintf("level(%d)", uint8(l))
+}
+
+// Behavior of the fatal and panic level log entries
+type FatalBehavior uint8
+
+const (
+	// The entry is only logged
+	BehaviorLog FatalBehavior = iota
+	// The entry is passed to Fatal of the logger, which exits the process
+	BehaviorExit
+	// The entry is passed to Panic of the logger
+	BehaviorPanic
+)
+
 const (
 	PanicLevel string = "panic"
 	FatalLevel        = "fatal"
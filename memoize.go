@@ -0,0 +1,133 @@
+package websockethandler
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Bounded LRU of the results of a pure handler keyed by the payload hash
+type memoCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[[sha256.Size]byte]*list.Element
+}
+
+type memoEntry struct {
+	key  [sha256.Size]byte
+	data WsFuncData
+}
+
+func (m *memoCache) get(key [sha256.Size]byte) (WsFuncData, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return WsFuncData{}, false
+	}
+	m.order.MoveToFront(el)
+	return el.Value.(*memoEntry).data, true
+}
+
+func (m *memoCache) put(key [sha256.Size]byte, data WsFuncData) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoEntry).data = data
+		m.order.MoveToFront(el)
+		return
+	}
+	m.entries[key] = m.order.PushFront(&memoEntry{key: key, data: data})
+	if m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoEntry).key)
+	}
+}
+
+// Hash of the event, status and canonical JSON of the data
+func payloadHash(p MessagePayload) ([sha256.Size]byte, error) {
+	b, err := json.Marshal(p.Data)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", p.Event, p.Status, b))), nil
+}
+
+// Memoizing the results of the event for identical payloads for the process lifetime.
+// Only for pure handlers: the result does not depend on the client or anything else.
+// The memo is the innermost step of the call, the middleware, the validator and
+// the concurrency limit run for memoized results too. Failed calls are not memoized.
+// The events of HandleMulti and HandleStream can not be memoized
+func (h *wsHandler) EnableMemoize(meta WsFunc, maxEntries int) WsHandler {
+	if h.err == nil {
+		if maxEntries <= 0 {
+			h.err = fmt.Errorf("not a valid memoize size:%d:%s", maxEntries, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		meta = h.normalize(meta)
+		if _, ok := h.multi[meta]; ok {
+			h.err = fmt.Errorf("a multi event can not be memoized:%s:%s", meta, getFunctionName())
+			return h
+		}
+		if _, ok := h.streams[meta]; ok {
+			h.err = fmt.Errorf("a stream event can not be memoized:%s:%s", meta, getFunctionName())
+			return h
+		}
+		h.memo[meta] = &memoCache{
+			maxEntries: maxEntries,
+			order:      list.New(),
+			entries:    make(map[[sha256.Size]byte]*list.Element),
+		}
+	}
+	return h
+}
+
+// Wrapping the handler into the memo of the event, must be called under the read lock.
+// The input is hashed as it reaches the handler, after the middleware
+func (h *wsHandler) memoized(meta WsFunc, f HandlerFunc) HandlerFunc {
+	memo, ok := h.memo[meta]
+	if !ok {
+		return f
+	}
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		key, err := payloadHash(data.Payload)
+		if err != nil {
+			return f(ctx, data)
+		}
+		if d, ok := memo.get(key); ok {
+			d.Client = data.Client
+			d.Payload.RequestID = data.Payload.RequestID
+			return d, nil
+		}
+		d, err := f(ctx, data)
+		if err == nil {
+			memo.put(key, d)
+		}
+		return d, err
+	}
+}
+
+// Dropping the memoized results of the event
+func (h *wsHandler) ClearMemoize(meta WsFunc) WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	meta = h.normalize(meta)
+	if m, ok := h.memo[meta]; ok {
+		m.clear()
+	}
+	return h
+}
+
+func (m *memoCache) clear() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.order.Init()
+	m.entries = make(map[[sha256.Size]byte]*list.Element)
+}
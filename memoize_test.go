@@ -0,0 +1,58 @@
+package websockethandler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoizedResultPassesMiddleware(t *testing.T) {
+	h := newTestHandler(t)
+	meta := WsFunc{Event: "pure"}
+	calls, wrapped := 0, 0
+	h.Handle(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		calls++
+		data.Payload.Data = "computed"
+		return data, nil
+	})
+	h.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			wrapped++
+			return next(ctx, data)
+		}
+	})
+	h.EnableMemoize(meta, 4)
+	for i := 0; i < 2; i++ {
+		out, err := h.CallFunc(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event, Data: 1}})
+		if err != nil || out.Payload.Data != "computed" {
+			t.Fatalf("call %d = %v, %v, want Data computed", i, out.Payload.Data, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if wrapped != 2 {
+		t.Fatalf("middleware called %d times, want 2", wrapped)
+	}
+}
+
+func TestEnableMemoizeRejectsMultiAndStream(t *testing.T) {
+	multi := func(ctx context.Context, data WsFuncData) ([]WsFuncData, error) { return nil, nil }
+	stream := func(ctx context.Context, data WsFuncData, emit *Emitter) error { return nil }
+
+	h := newTestHandler(t).HandleMulti(WsFunc{Event: "multi"}, multi).EnableMemoize(WsFunc{Event: "multi"}, 4)
+	if h.GetError() == nil {
+		t.Fatal("memoizing a multi event succeeded")
+	}
+	h = newTestHandler(t).HandleStream(WsFunc{Event: "stream"}, stream).EnableMemoize(WsFunc{Event: "stream"}, 4)
+	if h.GetError() == nil {
+		t.Fatal("memoizing a stream event succeeded")
+	}
+	h = newTestHandler(t).EnableMemoize(WsFunc{Event: "multi"}, 4).HandleMulti(WsFunc{Event: "multi"}, multi)
+	if h.GetError() == nil {
+		t.Fatal("registering a memoized multi event succeeded")
+	}
+	h = newTestHandler(t).EnableMemoize(WsFunc{Event: "stream"}, 4).HandleStream(WsFunc{Event: "stream"}, stream)
+	if h.GetError() == nil {
+		t.Fatal("registering a memoized stream event succeeded")
+	}
+}
@@ -0,0 +1,111 @@
+package websockethandler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Counters of the event handler invocations
+type EventMetrics struct {
+	Calls    uint64
+	Errors   uint64
+	Duration time.Duration
+}
+
+// Metrics of all events at a point in time
+type MetricsState struct {
+	At     time.Time
+	Events map[WsFunc]EventMetrics
+}
+
+// Change of the metrics between two states
+type MetricsDelta struct {
+	Elapsed time.Duration
+	Events  map[WsFunc]EventMetrics
+}
+
+// Receiver of the handler invocations, e.g. to export them without the package
+// depending on a metrics library. It is called after every handler call and
+// every pipeline stage without the lock of the handler, memoized results included
+type MetricsSink interface {
+	ObserveHandler(meta WsFunc, dur time.Duration, err error)
+}
+
+// Optional interface of MetricsSink receiving the time the call waited
+// for a slot of the goroutine budget
+type QueueWaitObserver interface {
+	ObserveQueueWait(meta WsFunc, d time.Duration)
+}
+
+// Setting the receiver of the handler invocations, nil disables it
+func (h *wsHandler) SetMetricsSink(sink MetricsSink) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.metricsSink = sink
+	}
+	return h
+}
+
+// Passing the wait for the goroutine budget to the sink and to the debug log
+func (h *wsHandler) observeQueueWait(cfg callConfig, meta WsFunc, d time.Duration) {
+	h.log(
+		debugLevel,
+		fmt.Errorf("queue wait:%s:%s:%s", meta, d, getFunctionName()),
+	)
+	if observer, ok := cfg.metricsSink.(QueueWaitObserver); ok {
+		observer.ObserveQueueWait(meta, d)
+	}
+}
+
+type metrics struct {
+	mutex  sync.Mutex
+	events map[WsFunc]EventMetrics
+}
+
+func (m *metrics) observe(meta WsFunc, dur time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	e := m.events[meta]
+	e.Calls++
+	if err != nil {
+		e.Errors++
+	}
+	e.Duration += dur
+	m.events[meta] = e
+}
+
+// Copy of the current metrics of the handler
+func (h *wsHandler) MetricsSnapshot() MetricsState {
+	h.metrics.mutex.Lock()
+	defer h.metrics.mutex.Unlock()
+	state := MetricsState{
+		At:     time.Now(),
+		Events: make(map[WsFunc]EventMetrics, len(h.metrics.events)),
+	}
+	for meta, e := range h.metrics.events {
+		state.Events[meta] = e
+	}
+	return state
+}
+
+// Per-event change of the metrics from a to b, events without changes are omitted
+func DiffMetrics(a, b MetricsState) MetricsDelta {
+	delta := MetricsDelta{
+		Elapsed: b.At.Sub(a.At),
+		Events:  make(map[WsFunc]EventMetrics),
+	}
+	for meta, eb := range b.Events {
+		ea := a.Events[meta]
+		d := EventMetrics{
+			Calls:    eb.Calls - ea.Calls,
+			Errors:   eb.Errors - ea.Errors,
+			Duration: eb.Duration - ea.Duration,
+		}
+		if d != (EventMetrics{}) {
+			delta.Events[meta] = d
+		}
+	}
+	return delta
+}
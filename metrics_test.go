@@ -0,0 +1,81 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Sink recording the observed invocations
+type recordingSink struct {
+	mutex sync.Mutex
+	calls map[WsFunc][]error
+}
+
+func (s *recordingSink) ObserveHandler(meta WsFunc, dur time.Duration, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[WsFunc][]error)
+	}
+	s.calls[meta] = append(s.calls[meta], err)
+}
+
+func (s *recordingSink) observed(meta WsFunc) []error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]error(nil), s.calls[meta]...)
+}
+
+func TestMetricsSinkObservesEveryCallWithItsError(t *testing.T) {
+	failure := errors.New("failed")
+	sink := &recordingSink{}
+	ok, failing := WsFunc{Event: "ok"}, WsFunc{Event: "failing"}
+	h := newTestHandler(t).SetMetricsSink(sink).
+		Handle(ok, stage("o")).
+		Handle(failing, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			return data, failure
+		})
+
+	h.CallFunc(context.Background(), ok, WsFuncData{Payload: MessagePayload{Event: ok.Event}})
+	h.CallFunc(context.Background(), ok, WsFuncData{Payload: MessagePayload{Event: ok.Event}})
+	h.CallFunc(context.Background(), failing, WsFuncData{Payload: MessagePayload{Event: failing.Event}})
+
+	if got := sink.observed(ok); len(got) != 2 || got[0] != nil || got[1] != nil {
+		t.Fatalf("observed ok = %v, want two calls without error", got)
+	}
+	if got := sink.observed(failing); len(got) != 1 || !errors.Is(got[0], failure) {
+		t.Fatalf("observed failing = %v, want one call with the handler error", got)
+	}
+}
+
+func TestMetricsSinkObservesEveryPipelineStage(t *testing.T) {
+	sink := &recordingSink{}
+	root, next := WsFunc{Event: "root"}, WsFunc{Event: "next"}
+	parent := stage("a")
+	h := newTestHandler(t).SetMetricsSink(sink).
+		Handle(root, parent).
+		Handle(next, stage("b"), parent)
+
+	if _, err := h.CallPipelineFuncSync(context.Background(), root, WsFuncData{Payload: MessagePayload{Event: root.Event}}); err != nil {
+		t.Fatalf("call pipeline: %v", err)
+	}
+	if got := sink.observed(root); len(got) != 1 {
+		t.Fatalf("observed root = %v, want one call", got)
+	}
+	if got := sink.observed(next); len(got) != 1 {
+		t.Fatalf("observed next = %v, want one call", got)
+	}
+}
+
+func TestSetMetricsSinkNilDisablesIt(t *testing.T) {
+	sink := &recordingSink{}
+	meta := WsFunc{Event: "ok"}
+	h := newTestHandler(t).SetMetricsSink(sink).SetMetricsSink(nil).Handle(meta, stage("o"))
+	h.CallFunc(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}})
+	if got := sink.observed(meta); len(got) != 0 {
+		t.Fatalf("observed = %v after the sink was removed", got)
+	}
+}
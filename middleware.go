@@ -0,0 +1,58 @@
+package websockethandler
+
+// Wrapper around the handler call, it may run code before and after
+// the next handler or return an error without calling it
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Adding the middleware after the ones added by Use before,
+// the first added runs outermost. A middleware returning an error without
+// calling the next handler is logged as a handler error
+func (h *wsHandler) Use(mw Middleware) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.middleware = append(h.middleware, mw)
+	}
+	return h
+}
+
+// Adding the middleware in front of all others, e.g. for panic recovery or tracing
+func (h *wsHandler) UseFirst(mw Middleware) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.middlewareFirst = append([]Middleware{mw}, h.middlewareFirst...)
+	}
+	return h
+}
+
+// Adding the middleware behind all others, right next to the handler
+func (h *wsHandler) UseLast(mw Middleware) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.middlewareLast = append(h.middlewareLast, mw)
+	}
+	return h
+}
+
+// Composing the middleware around the handler.
+// From the outermost: global, group, per-event.
+// The global ones are UseFirst in reverse order of the calls,
+// Use in order of the calls, UseLast in order of the calls
+func (h *wsHandler) wrap(meta WsFunc, f HandlerFunc) HandlerFunc {
+	f = wrapAll(f, h.eventMiddleware[meta])
+	if g, ok := h.groups[meta]; ok {
+		f = wrapAll(f, g.middleware)
+	}
+	f = wrapAll(f, h.middlewareLast)
+	f = wrapAll(f, h.middleware)
+	return wrapAll(f, h.middlewareFirst)
+}
+
+func wrapAll(f HandlerFunc, mws []Middleware) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		f = mws[i](f)
+	}
+	return f
+}
@@ -0,0 +1,83 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+// Middleware recording the events it has seen before and after the next handler
+type callLog struct {
+	mutex sync.Mutex
+	calls []string
+}
+
+func (l *callLog) middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		l.add("before " + data.Payload.Event)
+		d, err := next(ctx, data)
+		l.add("after " + data.Payload.Event)
+		return d, err
+	}
+}
+
+func (l *callLog) add(call string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+func (l *callLog) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return strings.Join(l.calls, ", ")
+}
+
+// Middleware rejecting the calls of the clients other than admin
+func auth(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		if data.Client != "admin" {
+			return WsFuncData{Client: data.Client, Payload: MessagePayload{Event: data.Payload.Event, Status: ErrorLevel}}, errUnauthorized
+		}
+		return next(ctx, data)
+	}
+}
+
+func TestMiddlewareLogsAndAuthorizes(t *testing.T) {
+	logs := &logBuffer{}
+	calls := &callLog{}
+	called := 0
+	h := newTestHandler(t).AddLogger(log.New(logs, "", 0)).
+		Use(calls.middleware).
+		Use(auth).
+		Handle(WsFunc{Event: "drop"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			called++
+			return data, nil
+		})
+
+	if _, err := h.CallFunc(context.Background(), WsFunc{Event: "drop"}, WsFuncData{Client: "admin", Payload: MessagePayload{Event: "drop"}}); err != nil {
+		t.Fatalf("call of admin: %v", err)
+	}
+	if called != 1 {
+		t.Fatalf("handler called %d times for admin, want 1", called)
+	}
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "drop"}, WsFuncData{Client: "guest", Payload: MessagePayload{Event: "drop"}})
+	if !errors.Is(err, errUnauthorized) || out.Payload.Status != ErrorLevel {
+		t.Fatalf("call of guest = %+v, %v, want the rejection of auth", out.Payload, err)
+	}
+	if called != 1 {
+		t.Fatal("auth has called the handler for guest")
+	}
+	if got, want := calls.String(), "before drop, after drop, before drop, after drop"; got != want {
+		t.Fatalf("logging middleware saw %q, want %q", got, want)
+	}
+	if !strings.Contains(logs.String(), errUnauthorized.Error()) {
+		t.Fatalf("the rejection is not logged: %q", logs.String())
+	}
+}
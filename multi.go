@@ -0,0 +1,74 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Handler producing several responses for one request
+type MultiHandlerFunc func(context.Context, WsFuncData) ([]WsFuncData, error)
+
+// Registration of the function returning several responses,
+// such functions can not be a part of a pipeline
+func (h *wsHandler) HandleMulti(meta WsFunc, f MultiHandlerFunc) WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	meta = h.normalize(meta)
+	if h.isRegistered(meta) {
+		h.duplicates = append(h.duplicates, meta)
+		if h.err == nil {
+			h.err = fmt.Errorf("%w:%s:%s", ErrAlreadyRegistered, meta, getFunctionName())
+		}
+		return h
+	}
+	if _, ok := h.memo[meta]; ok && h.err == nil {
+		h.err = fmt.Errorf("a multi event can not be memoized:%s:%s", meta, getFunctionName())
+		return h
+	}
+	if h.err == nil {
+		h.multi[meta] = f
+	}
+	return h
+}
+
+// Calling the event registered by HandleMulti.
+// The entry of the call is the one of CallFunc: the request id, the timeout
+// of WithCallTimeout, the entry checks, the access log and the broadcast outputs.
+// The context deadline and the error handling apply to the whole call
+func (h *wsHandler) CallMulti(ctx context.Context, meta WsFunc, data WsFuncData) ([]WsFuncData, error) {
+	return h.call(ctx, meta, data, h.dispatchMulti)
+}
+
+// Must be called under the read lock
+func (h *wsHandler) dispatchMulti(ctx context.Context, meta WsFunc, data WsFuncData) ([]WsFuncData, error) {
+	f, ok := h.multi[meta]
+	if !ok {
+		d, err := h.notRegistered(meta, data)
+		return []WsFuncData{d}, err
+	}
+
+	// The results are read only after the wrapper has returned
+	var out []WsFuncData
+	wrapper := func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		res, err := f(ctx, data)
+		if err != nil {
+			return WsFuncData{Client: data.Client, Payload: MessagePayload{Event: data.Payload.Event, Status: ErrorLevel}}, err
+		}
+		out = res
+		return WsFuncData{Client: data.Client, Payload: MessagePayload{Event: data.Payload.Event}}, nil
+	}
+	d, err := h.shell(wrapper, ctx, meta, data)
+	if err != nil {
+		return []WsFuncData{d}, fmt.Errorf("%w:%s:%s", err, meta, getFunctionName())
+	}
+	transformer := h.transformerOf(meta)
+	h.unlocked(func() {
+		for i := range out {
+			if out[i].Payload.RequestID == "" {
+				out[i].Payload.RequestID = data.Payload.RequestID
+			}
+			out[i] = transform(transformer, meta, out[i])
+		}
+	})
+	return out, nil
+}
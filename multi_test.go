@@ -0,0 +1,63 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallMultiSharesTheEntryOfCallFunc(t *testing.T) {
+	meta := WsFunc{Event: "fanout"}
+	var entries []AccessLogEntry
+	client := make(chan MessagePayload, 1)
+	h := newTestHandler(t).
+		HandleMulti(meta, func(ctx context.Context, data WsFuncData) ([]WsFuncData, error) {
+			return []WsFuncData{
+				{Payload: MessagePayload{Event: "one"}},
+				{Payload: MessagePayload{Event: "all", Broadcast: true}},
+			}, nil
+		}).
+		SetAccessLogger(func(e AccessLogEntry) { entries = append(entries, e) }).
+		RegisterClient("c1", client)
+
+	out, err := h.CallMulti(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}})
+	if err != nil || len(out) != 2 {
+		t.Fatalf("call multi = %+v, %v, want two outputs", out, err)
+	}
+	if len(entries) != 1 || entries[0].RequestID == "" {
+		t.Fatalf("access log = %+v, want one entry with the request id", entries)
+	}
+	for _, d := range out {
+		if d.Payload.RequestID != entries[0].RequestID {
+			t.Fatalf("output %+v without the request id %s", d.Payload, entries[0].RequestID)
+		}
+	}
+	select {
+	case p := <-client:
+		if p.Event != "all" {
+			t.Fatalf("broadcast %+v, want the event all", p)
+		}
+	default:
+		t.Fatal("the broadcast output was not delivered")
+	}
+}
+
+func TestCallMultiObservesTheCallTimeout(t *testing.T) {
+	meta := WsFunc{Event: "slow"}
+	h := newTestHandler(t).HandleMulti(meta, func(ctx context.Context, data WsFuncData) ([]WsFuncData, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	start := time.Now()
+	out, err := h.CallMulti(WithCallTimeout(context.Background(), 20*time.Millisecond), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if len(out) != 1 || out[0].Payload.Code != CodeTimeout {
+		t.Fatalf("outputs = %+v, want one timeout payload", out)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the call took %s", elapsed)
+	}
+}
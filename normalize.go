@@ -0,0 +1,32 @@
+package websockethandler
+
+import "strings"
+
+// Built-in event normalizer: lower case without surrounding whitespace
+func NormalizeEvent(event string) string {
+	return strings.ToLower(strings.TrimSpace(event))
+}
+
+// Setting the normalizer applied to events at registration and before the lookup,
+// so that e.g. "Order.Created " routes to "order.created". Disabled by default.
+// It must be idempotent and set before the registration of handlers
+func (h *wsHandler) SetEventNormalizer(f func(string) string) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.normalizer = f
+	}
+	return h
+}
+
+func (h *wsHandler) normalizeEvent(event string) string {
+	if h.normalizer == nil {
+		return event
+	}
+	return h.normalizer(event)
+}
+
+func (h *wsHandler) normalize(meta WsFunc) WsFunc {
+	meta.Event = h.normalizeEvent(meta.Event)
+	return meta
+}
@@ -0,0 +1,30 @@
+package websockethandler
+
+import "time"
+
+const defaultPipelineTimeout = time.Second * 30
+
+// Option of the handler passed to NewHandler
+type Option func(*wsHandler)
+
+// Setting the timeout of each pipeline stage, 30 seconds by default.
+// Zero disables it, stages then run within the deadline of the caller's context
+func WithPipelineTimeout(d time.Duration) Option {
+	return func(h *wsHandler) {
+		if d < 0 {
+			d = 0
+		}
+		h.pipelineTimeout = d
+	}
+}
+
+// Setting the clock of the log entries, the handler durations and the access log,
+// e.g. a fake clock making benchmark results independent of the wall time.
+// Timeouts and deadlines always run on the real time, nil keeps time.Now
+func WithClock(now func() time.Time) Option {
+	return func(h *wsHandler) {
+		if now != nil {
+			h.now = now
+		}
+	}
+}
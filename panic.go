@@ -0,0 +1,51 @@
+package websockethandler
+
+import (
+	"fmt"
+)
+
+// Value of the recovered panic and the stack of the panicking goroutine
+type PanicInfo struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p PanicInfo) Error() string {
+	return fmt.Sprintf("handler panic:%v", p.Value)
+}
+
+// Building the response for a recovered panic of the handler
+type PanicHandler func(meta WsFunc, data WsFuncData, info PanicInfo) WsFuncData
+
+// Setting the builder of responses for recovered panics,
+// nil restores the default error payload with the panic value
+func (h *wsHandler) SetPanicHandler(f PanicHandler) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.panicHandler = f
+	}
+	return h
+}
+
+// Logging the recovered panic with the stack and building the response
+func (h *wsHandler) recovered(cfg callConfig, meta WsFunc, data WsFuncData, info PanicInfo) (WsFuncData, error) {
+	h.log(
+		errorLevel,
+		fmt.Errorf("%w:%s:%s", info, meta, getFunctionName()),
+		data.Payload,
+		data.Client,
+		string(info.Stack),
+	)
+	if cfg.panicHandler != nil {
+		return cfg.panicHandler(meta, data, info), info
+	}
+	return WsFuncData{
+		Client: data.Client,
+		Payload: MessagePayload{
+			Event:  data.Payload.Event,
+			Status: ErrorLevel,
+			Data:   fmt.Sprint(info.Value),
+		},
+	}, info
+}
@@ -0,0 +1,86 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func panicking(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+	panic("boom")
+}
+
+func TestCallFuncRecoversThePanic(t *testing.T) {
+	logs := &logBuffer{}
+	h := newTestHandler(t).AddLogger(log.New(logs, "", 0)).Handle(WsFunc{Event: "boom"}, panicking)
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "boom"}, WsFuncData{Payload: MessagePayload{Event: "boom"}})
+	var info PanicInfo
+	if !errors.As(err, &info) || info.Value != "boom" {
+		t.Fatalf("err = %v, want the PanicInfo of boom", err)
+	}
+	if out.Payload.Status != ErrorLevel || out.Payload.Data != "boom" || out.Payload.Event != "boom" {
+		t.Fatalf("payload = %+v, want the error payload with the panic value", out.Payload)
+	}
+	entry := logs.String()
+	if !strings.Contains(entry, "handler panic:boom") || !strings.Contains(entry, "panic_test.go") {
+		t.Fatalf("log %q, want the panic with the stack of the handler", entry)
+	}
+}
+
+func TestCallPipelineFuncRecoversThePanic(t *testing.T) {
+	logs := &logBuffer{}
+	root := stage("a")
+	h := newTestHandler(t).AddLogger(log.New(logs, "", 0)).
+		Handle(WsFunc{Event: "root"}, root).
+		Handle(WsFunc{Event: "boom"}, panicking, root)
+
+	ch := make(chan MessagePayload)
+	read := make(chan []MessagePayload)
+	go func() {
+		var payloads []MessagePayload
+		for p := range ch {
+			payloads = append(payloads, p)
+		}
+		read <- payloads
+	}()
+	done := make(chan error)
+	go func() {
+		done <- h.CallPipelineFunc(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}, ch)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the panicking stage has blocked the pipeline")
+	}
+	close(ch)
+	payloads := <-read
+
+	var info PanicInfo
+	if !errors.As(err, &info) {
+		t.Fatalf("err = %v, want the PanicInfo of the stage", err)
+	}
+	if len(payloads) != 2 || payloads[0].Data != "a" || payloads[1].Status != ErrorLevel || payloads[1].Data != "boom" {
+		t.Fatalf("payloads = %+v, want the root output and the error payload of the panic", payloads)
+	}
+	if !strings.Contains(logs.String(), "handler panic:boom") {
+		t.Fatalf("the panic is not logged: %q", logs.String())
+	}
+}
+
+func TestPanicHandlerBuildsTheResponse(t *testing.T) {
+	h := newTestHandler(t).
+		SetPanicHandler(func(meta WsFunc, data WsFuncData, info PanicInfo) WsFuncData {
+			return WsFuncData{Payload: MessagePayload{Event: meta.Event, Status: ErrorLevel, Data: "internal error"}}
+		}).
+		Handle(WsFunc{Event: "boom"}, panicking)
+
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "boom"}, WsFuncData{Payload: MessagePayload{Event: "boom"}})
+	if err == nil || out.Payload.Data != "internal error" {
+		t.Fatalf("call = %+v, %v, want the response of the panic handler", out.Payload, err)
+	}
+}
@@ -0,0 +1,49 @@
+package websockethandler
+
+import (
+	"context"
+	"sync"
+)
+
+// Calling an event in pipeline mode with the children of every stage run concurrently.
+// The outputs of the children are sent to the channel in the order of completion
+func (h *wsHandler) CallPipelineFuncParallel(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload) error {
+	return h.CallPipelineFuncWithOptions(ctx, meta, data, ch, PipelineOptions{Parallel: true})
+}
+
+// Running the children of the stage, concurrently in the parallel mode.
+// Every goroutine takes its own read lock, the caller waits for them without the lock.
+// The goroutines take no slot of the goroutine budget, the handlers they run do
+func (h *wsHandler) runChildren(run *pipelineRun, children []*wsHandlerTree, data WsFuncData, index int) bool {
+	if !run.opts.Parallel || len(children) < 2 {
+		for _, child := range children {
+			if !h.runNode(run, child, data, index) {
+				return false
+			}
+		}
+		return true
+	}
+
+	results := make([]bool, len(children))
+	h.unlocked(func() {
+		var wg sync.WaitGroup
+		for i, child := range children {
+			release := h.counted()
+			wg.Add(1)
+			go func(i int, child *wsHandlerTree) {
+				defer wg.Done()
+				defer release()
+				h.mutex.RLock()
+				defer h.mutex.RUnlock()
+				results[i] = h.runNode(run, child, data, index)
+			}(i, child)
+		}
+		wg.Wait()
+	})
+	for _, ok := range results {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
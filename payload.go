@@ -0,0 +1,26 @@
+package websockethandler
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Creating the outbound payload of the event
+func NewMessagePayload(event string, data interface{}) MessagePayload {
+	return MessagePayload{Event: event, Data: data}
+}
+
+// Decoding Data into v, which must be a pointer.
+// Data is marshaled back to JSON and unmarshaled into v,
+// so a map decoded from the inbound JSON becomes the concrete struct.
+// A nil Data leaves v unchanged
+func (p MessagePayload) DecodeData(v interface{}) error {
+	raw, err := json.Marshal(p.Data)
+	if err != nil {
+		return fmt.Errorf("marshal data:%w:%s:%s", err, p.Event, getFunctionName())
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("unmarshal data:%w:%s:%s", err, p.Event, getFunctionName())
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package websockethandler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type order struct {
+	ID    int      `json:"id"`
+	Items []string `json:"items"`
+}
+
+func TestDecodeDataOfInboundJSON(t *testing.T) {
+	var p MessagePayload
+	if err := json.Unmarshal([]byte(`{"event":"order","data":{"id":7,"items":["a","b"]}}`), &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var o order
+	if err := p.DecodeData(&o); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if o.ID != 7 || len(o.Items) != 2 || o.Items[1] != "b" {
+		t.Fatalf("order = %+v, want id 7 with two items", o)
+	}
+}
+
+func TestDecodeDataErrors(t *testing.T) {
+	var o order
+	if err := NewMessagePayload("order", "not an object").DecodeData(&o); err == nil {
+		t.Fatal("decoding a string into a struct succeeded")
+	}
+	if err := NewMessagePayload("order", func() {}).DecodeData(&o); err == nil {
+		t.Fatal("decoding a func succeeded")
+	}
+	o = order{ID: 1}
+	if err := NewMessagePayload("order", nil).DecodeData(&o); err != nil || o.ID != 1 {
+		t.Fatalf("decoding nil = %+v, %v, want v unchanged", o, err)
+	}
+}
+
+func TestNewMessagePayloadRoundTrip(t *testing.T) {
+	b, err := json.Marshal(NewMessagePayload("order", order{ID: 3}))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var p MessagePayload
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var o order
+	if err := p.DecodeData(&o); err != nil || p.Event != "order" || o.ID != 3 {
+		t.Fatalf("round trip = %+v, %+v, %v", p, o, err)
+	}
+}
@@ -0,0 +1,105 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+type pinnedJob struct {
+	ctx   context.Context
+	data  WsFuncData
+	reply chan pinnedResult
+}
+
+type pinnedResult struct {
+	data WsFuncData
+	err  error
+}
+
+// Registration of the function executed by a dedicated worker locked to its OS thread.
+// All calls of the event are serialized onto that thread, which is required
+// by thread-affine native libraries. The worker stops when the event is deregistered
+// or replaced, pinned functions can not be a part of a pipeline
+func (h *wsHandler) HandlePinned(meta WsFunc, f HandlerFunc) WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	meta = h.normalize(meta)
+	if h.isRegistered(meta) {
+		h.duplicates = append(h.duplicates, meta)
+		if h.err == nil {
+			h.err = fmt.Errorf("%w:%s:%s", ErrAlreadyRegistered, meta, getFunctionName())
+		}
+		return h
+	}
+	if h.err == nil {
+		h.fun[meta], h.pinned[meta] = startPinned(meta, f)
+	}
+	return h
+}
+
+// Starting the worker of the function, the returned func stops it.
+// A call arriving after the stop gets ErrHandlerNotRegistered
+func startPinned(meta WsFunc, f HandlerFunc) (HandlerFunc, func()) {
+	jobs, quit := make(chan pinnedJob), make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		for {
+			select {
+			case job := <-jobs:
+				job.reply <- callPinned(f, job)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		failed := WsFuncData{
+			Client:  data.Client,
+			Payload: MessagePayload{Event: data.Payload.Event, Status: ErrorLevel},
+		}
+		reply := make(chan pinnedResult, 1)
+		select {
+		case jobs <- pinnedJob{ctx: ctx, data: data, reply: reply}:
+		case <-quit:
+			return failed, fmt.Errorf("%w:%s:%s", ErrHandlerNotRegistered, meta, getFunctionName())
+		case <-ctx.Done():
+			return failed, ctx.Err()
+		}
+		select {
+		case r := <-reply:
+			return r.data, r.err
+		case <-ctx.Done():
+			return failed, ctx.Err()
+		}
+	}, func() { close(quit) }
+}
+
+// Stopping the worker of the pinned event, must be called under the write lock
+func (h *wsHandler) stopPinned(meta WsFunc) {
+	if stop, ok := h.pinned[meta]; ok {
+		stop()
+		delete(h.pinned, meta)
+	}
+}
+
+// A panic is passed to the caller instead of killing the worker
+func callPinned(f HandlerFunc, job pinnedJob) (r pinnedResult) {
+	defer func() {
+		if v := recover(); v != nil {
+			info := PanicInfo{Value: v, Stack: debug.Stack()}
+			r = pinnedResult{
+				data: WsFuncData{
+					Client:  job.data.Client,
+					Payload: MessagePayload{Event: job.data.Payload.Event, Status: ErrorLevel, Data: fmt.Sprint(v)},
+				},
+				err: info,
+			}
+		}
+	}()
+	d, err := f(job.ctx, job.data)
+	return pinnedResult{data: d, err: err}
+}
@@ -0,0 +1,37 @@
+package websockethandler
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRemovingPinnedStopsTheWorker(t *testing.T) {
+	h := newTestHandler(t)
+	before := runtime.NumGoroutine()
+	h.HandlePinned(WsFunc{Event: "deregistered"}, stage("d"))
+	h.HandlePinned(WsFunc{Event: "replaced"}, stage("r"))
+	for _, event := range []string{"deregistered", "replaced"} {
+		if _, err := h.CallFunc(context.Background(), WsFunc{Event: event}, WsFuncData{Payload: MessagePayload{Event: event}}); err != nil {
+			t.Fatalf("call %s: %v", event, err)
+		}
+	}
+	h.Deregister(WsFunc{Event: "deregistered"})
+	h.Replace(WsFunc{Event: "replaced"}, stage("n"))
+	if err := h.GetError(); err != nil {
+		t.Fatalf("remove pinned: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "replaced"}, WsFuncData{Payload: MessagePayload{Event: "replaced"}})
+	if err != nil || out.Payload.Data != "n" {
+		t.Fatalf("call replaced = %v, %v, want Data n", out.Payload.Data, err)
+	}
+}
@@ -0,0 +1,77 @@
+package websockethandler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Fixed window counter of requests per client
+type clientQuota struct {
+	mutex     sync.Mutex
+	max       int
+	window    time.Duration
+	keyFunc   func(WsFuncData) string
+	counters  map[string]*quotaCounter
+	lastSweep time.Time
+}
+
+type quotaCounter struct {
+	start time.Time
+	count int
+}
+
+func (q *clientQuota) allow(data WsFuncData) bool {
+	key := q.keyFunc(data)
+	now := time.Now()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	// Removing counters of expired windows so that the map does not grow
+	if now.Sub(q.lastSweep) >= q.window {
+		for k, c := range q.counters {
+			if now.Sub(c.start) >= q.window {
+				delete(q.counters, k)
+			}
+		}
+		q.lastSweep = now
+	}
+
+	c, ok := q.counters[key]
+	if !ok || now.Sub(c.start) >= q.window {
+		c = &quotaCounter{start: now}
+		q.counters[key] = c
+	}
+	if c.count >= q.max {
+		return false
+	}
+	c.count++
+	return true
+}
+
+// Setting the limit of requests per client within the window regardless of event.
+// The client is identified by keyFunc, max <= 0 disables the quota
+func (h *wsHandler) SetClientQuota(max int, window time.Duration, keyFunc func(WsFuncData) string) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if max <= 0 {
+			h.quota = nil
+			return h
+		}
+		if window <= 0 || keyFunc == nil {
+			h.err = fmt.Errorf("invalid client quota params:%v:%s", window, getFunctionName())
+			return h
+		}
+		h.quota = &clientQuota{
+			max:       max,
+			window:    window,
+			keyFunc:   keyFunc,
+			counters:  make(map[string]*quotaCounter),
+			lastSweep: time.Now(),
+		}
+		h.log(infoLevel,
+			fmt.Errorf("set client quota to %d per %v", max, window))
+	}
+	return h
+}
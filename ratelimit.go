@@ -0,0 +1,114 @@
+package websockethandler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Token buckets of the clients
+type rateLimiter struct {
+	mutex     sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *rateLimiter) allow(id string) bool {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	// Removing the refilled buckets, they are the same as new ones
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) >= refill {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) >= refill {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[id] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Setting the rate of events per client with the burst regardless of event.
+// The client is identified as set by SetClientIdentity, clients without
+// the identity are not limited. eventsPerSec <= 0 disables the limit
+func (h *wsHandler) SetRateLimit(eventsPerSec float64, burst int) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if eventsPerSec <= 0 {
+			h.rateLimit = nil
+			return h
+		}
+		if burst < 1 {
+			h.err = fmt.Errorf("invalid rate limit params:%d:%s", burst, getFunctionName())
+			return h
+		}
+		h.rateLimit = &rateLimiter{
+			rate:      eventsPerSec,
+			burst:     float64(burst),
+			buckets:   make(map[string]*tokenBucket),
+			lastSweep: time.Now(),
+		}
+		h.log(infoLevel,
+			fmt.Errorf("set rate limit to %v per second with burst %d", eventsPerSec, burst))
+	}
+	return h
+}
+
+// Setting the identity of the client used by the rate limit and NotifyDisconnect.
+// By default it is the client itself for a string or the result of ClientID
+// for a ClientIdentifier. An empty identity means the client is unknown
+func (h *wsHandler) SetClientIdentity(f func(client interface{}) string) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.clientIdentity = f
+	}
+	return h
+}
+
+func (h *wsHandler) identify(client interface{}) string {
+	if h.clientIdentity != nil {
+		return h.clientIdentity(client)
+	}
+	switch c := client.(type) {
+	case string:
+		return c
+	case ClientIdentifier:
+		return c.ClientID()
+	}
+	return ""
+}
+
+// Checking the rate limit of the client of the call
+func (h *wsHandler) rateAllowed(data WsFuncData) bool {
+	if h.rateLimit == nil {
+		return true
+	}
+	id := h.identify(data.Client)
+	return id == "" || h.rateLimit.allow(id)
+}
@@ -0,0 +1,49 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRateLimitAllowsTheBurstPerClient(t *testing.T) {
+	meta := WsFunc{Event: "chat"}
+	h := newTestHandler(t).SetRateLimit(0.001, 2).Handle(meta, stage("c"))
+	call := func(client string) (WsFuncData, error) {
+		return h.CallFunc(context.Background(), meta, WsFuncData{Client: client, Payload: MessagePayload{Event: meta.Event, RequestID: "r1"}})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := call("ann"); err != nil {
+			t.Fatalf("call %d of the burst: %v", i, err)
+		}
+	}
+	out, err := call("ann")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if out.Payload.Status != ErrorLevel || out.Payload.Event != meta.Event || out.Payload.RequestID != "r1" {
+		t.Fatalf("payload = %+v, want the error payload of the request", out.Payload)
+	}
+	if _, err := call("bob"); err != nil {
+		t.Fatalf("another client was limited: %v", err)
+	}
+}
+
+func TestRateLimitSkipsTheClientWithoutIdentity(t *testing.T) {
+	meta := WsFunc{Event: "chat"}
+	h := newTestHandler(t).SetRateLimit(0.001, 1).
+		SetClientIdentity(func(client interface{}) string { return "" }).
+		Handle(meta, stage("c"))
+	for i := 0; i < 3; i++ {
+		if _, err := h.CallFunc(context.Background(), meta, WsFuncData{Client: "ann", Payload: MessagePayload{Event: meta.Event}}); err != nil {
+			t.Fatalf("call %d of the unknown client: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimitRejectsTheEmptyBurst(t *testing.T) {
+	if h := newTestHandler(t).SetRateLimit(1, 0); h.GetError() == nil {
+		t.Fatal("a burst of 0 was accepted")
+	}
+}
@@ -0,0 +1,29 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+type rawBytesCtx struct{}
+
+// Returns the received frame when the call was started by HandleRaw, nil otherwise
+func RawBytesFromContext(ctx context.Context) []byte {
+	raw, _ := ctx.Value(rawBytesCtx{}).([]byte)
+	return raw
+}
+
+// Decoding the received frame by the codec of the handler and calling the event from it.
+// The exact frame bytes are available to the handler via RawBytesFromContext
+func (h *wsHandler) HandleRaw(ctx context.Context, client interface{}, raw []byte) (WsFuncData, error) {
+	payload, err := h.Codec().Unmarshal(raw)
+	if err != nil {
+		return WsFuncData{Client: client, Payload: MessagePayload{Status: ErrorLevel}},
+			fmt.Errorf("%w:%s", err, getFunctionName())
+	}
+	frame := make([]byte, len(raw))
+	copy(frame, raw)
+	ctx = context.WithValue(ctx, rawBytesCtx{}, frame)
+	meta := WsFunc{Event: payload.Event, Status: payload.Status}
+	return h.CallFunc(ctx, meta, WsFuncData{Client: client, Payload: payload})
+}
@@ -0,0 +1,41 @@
+package websockethandler
+
+import "sync"
+
+// Events marked as not ready, all others are ready
+type readiness struct {
+	mutex    sync.RWMutex
+	notReady map[WsFunc]struct{}
+}
+
+// Marking the event ready or not ready, e.g. until its dependencies are warmed up.
+// Calls of a not ready event return ErrNotReady
+func (h *wsHandler) SetReady(meta WsFunc, ready bool) WsHandler {
+	h.mutex.RLock()
+	meta = h.normalize(meta)
+	h.mutex.RUnlock()
+
+	h.readiness.mutex.Lock()
+	defer h.readiness.mutex.Unlock()
+	if ready {
+		delete(h.readiness.notReady, meta)
+	} else {
+		h.readiness.notReady[meta] = struct{}{}
+	}
+	return h
+}
+
+// Reports whether the event is ready
+func (h *wsHandler) Ready(meta WsFunc) bool {
+	h.mutex.RLock()
+	meta = h.normalize(meta)
+	h.mutex.RUnlock()
+	return h.ready(meta)
+}
+
+func (h *wsHandler) ready(meta WsFunc) bool {
+	h.readiness.mutex.RLock()
+	defer h.readiness.mutex.RUnlock()
+	_, notReady := h.readiness.notReady[meta]
+	return !notReady
+}
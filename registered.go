@@ -0,0 +1,33 @@
+package websockethandler
+
+import "sort"
+
+// Checking whether the event has a registered handler, aliases are resolved
+func (h *wsHandler) IsRegistered(meta WsFunc) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.isRegistered(h.lookupAlias(meta))
+}
+
+// Snapshot of all registered events, sorted by event and status
+func (h *wsHandler) RegisteredEvents() []WsFunc {
+	h.mutex.RLock()
+	list := make([]WsFunc, 0, len(h.fun)+len(h.multi)+len(h.streams))
+	for meta := range h.fun {
+		list = append(list, meta)
+	}
+	for meta := range h.multi {
+		list = append(list, meta)
+	}
+	for meta := range h.streams {
+		list = append(list, meta)
+	}
+	h.mutex.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Event != list[j].Event {
+			return list[i].Event < list[j].Event
+		}
+		return list[i].Status < list[j].Status
+	})
+	return list
+}
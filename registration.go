@@ -0,0 +1,65 @@
+package websockethandler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Entry of the batch registration, Parent is optional
+type Registration struct {
+	Meta   WsFunc
+	Func   HandlerFunc
+	Parent HandlerFunc
+}
+
+// Registering every entry in order like Handle.
+// Failed entries are skipped, their errors are joined into the latched error
+// so that GetError tells which of the batch failed
+func (h *wsHandler) HandleAll(regs []Registration) WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.err != nil {
+		return h
+	}
+	var errs []error
+	for i, reg := range regs {
+		if err := h.registerEntry(i, reg); err != nil {
+			if errors.Is(err, ErrAlreadyRegistered) {
+				h.duplicates = append(h.duplicates, h.normalize(reg.Meta))
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		h.err = errors.Join(errs...)
+	}
+	return h
+}
+
+// Attempting every registration in order.
+// Failed entries are skipped and their errors are returned,
+// the error state of the handler is neither checked nor changed
+func (h *wsHandler) RegisterAll(regs []Registration) []error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var errs []error
+	for i, reg := range regs {
+		if err := h.registerEntry(i, reg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (h *wsHandler) registerEntry(i int, reg Registration) error {
+	var err error
+	if reg.Parent != nil {
+		err = h.register(reg.Meta, reg.Func, reg.Parent)
+	} else {
+		err = h.register(reg.Meta, reg.Func)
+	}
+	if err != nil {
+		return fmt.Errorf("registration %d:%s:%w", i, reg.Meta, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package websockethandler
+
+import "fmt"
+
+// Registering the function like Handle, overwriting the registration of the event if any.
+// The node of the replaced function keeps its children, the parent is validated as by Handle
+// and without it the function becomes a root. Handle stays strict, Replace is idempotent.
+// Events registered by HandleMulti or HandleStream can not be replaced
+func (h *wsHandler) Replace(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if err := h.replace(h.normalize(meta), f, parent...); err != nil {
+			h.err = err
+		}
+	}
+	return h
+}
+
+// Must be called under the write lock
+func (h *wsHandler) replace(meta WsFunc, f HandlerFunc, parent ...HandlerFunc) error {
+	if _, ok := h.multi[meta]; ok {
+		return fmt.Errorf("func with current params is not a handler func:%s:%s", meta, getFunctionName())
+	}
+	if _, ok := h.streams[meta]; ok {
+		return fmt.Errorf("func with current params is not a handler func:%s:%s", meta, getFunctionName())
+	}
+	old, ok := h.fun[meta]
+	if !ok {
+		return h.register(meta, f, parent...)
+	}
+	keyOld := keyOf(old)
+	node, ok := h.nodeOf(old)
+	if !ok {
+		// Pinned functions are not a part of the tree
+		delete(h.fun, meta)
+		h.stopPinned(meta)
+		return h.register(meta, f, parent...)
+	}
+	for m, fn := range h.fun {
+		if m != meta && keyOf(fn) == keyOld {
+			return fmt.Errorf("the function is shared with another event:%s:%s:%s", meta, m, getFunctionName())
+		}
+	}
+
+	keyMain := keyOf(f)
+	if _, ok := h.nodeOf(f); ok && keyMain != keyOld {
+		return fmt.Errorf("this function is declared:%s:%s", getHandlerName(f), getFunctionName())
+	}
+	var parentNode *wsHandlerTree
+	if len(parent) > 0 {
+		keyParent := keyOf(parent[0])
+		if keyParent == keyMain || keyParent == keyOld {
+			return fmt.Errorf("the function can not be its own parent:%s:%s", getHandlerName(f), getFunctionName())
+		}
+		parentNode, ok = h.nodeOf(parent[0])
+		if !ok {
+			return fmt.Errorf("%w:%s:%s:%s", ErrNoParent, getHandlerName(f), getHandlerName(parent[0]), getFunctionName())
+		}
+		for p := parentNode; p != nil; p = p.parent {
+			if p == node {
+				return fmt.Errorf("the parent function is a child of the function:%s:%s:%s", getHandlerName(f), getHandlerName(parent[0]), getFunctionName())
+			}
+		}
+	}
+
+	if node.parent != parentNode {
+		detach(node)
+		if parentNode != nil {
+			parentNode.children = append(parentNode.children, node)
+		}
+		node.parent = parentNode
+	}
+	// The node keeps its id, only the function of the id changes
+	delete(h.funcIDs, keyOld)
+	node.meta = meta
+	node.main = f
+	h.funcIDs[keyMain] = node.id
+	h.fun[meta] = f
+	if m, ok := h.memo[meta]; ok {
+		// The results of the replaced function are stale
+		m.clear()
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package websockethandler
+
+import "github.com/google/uuid"
+
+// Generating the request id of the inbound payload if the client has not supplied one
+func withRequestID(data WsFuncData) WsFuncData {
+	if data.Payload.RequestID == "" {
+		data.Payload.RequestID = uuid.NewString()
+	}
+	return data
+}
+
+// Request id of the first payload among the log entry data
+func requestIDOf(data []interface{}) string {
+	for _, v := range data {
+		switch v := v.(type) {
+		case MessagePayload:
+			if v.RequestID != "" {
+				return v.RequestID
+			}
+		case WsFuncData:
+			if v.Payload.RequestID != "" {
+				return v.Payload.RequestID
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Number of attempts of the failing handler and the pause between them
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+}
+
+// Retrying the failed handler of the event up to attempts times in total,
+// with the backoff pause between the attempts. Less than two attempts disable it.
+// All attempts share the deadline of the call, the done context stops the retries
+func WithRetry(meta WsFunc, attempts int, backoff time.Duration) Option {
+	return func(h *wsHandler) {
+		meta = h.normalize(meta)
+		if attempts < 2 {
+			delete(h.retries, meta)
+			return
+		}
+		if backoff < 0 {
+			backoff = 0
+		}
+		h.retries[meta] = retryPolicy{attempts: attempts, backoff: backoff}
+	}
+}
+
+// Running the handler with respect to the retry policy of the event
+func (h *wsHandler) executeRetrying(cfg callConfig, retry retryPolicy, f HandlerFunc, ctx context.Context, meta WsFunc, data WsFuncData) (WsFuncData, error) {
+	d, err := h.execute(cfg, f, ctx, meta, data)
+	for attempt := 2; err != nil && attempt <= retry.attempts && ctx.Err() == nil; attempt++ {
+		h.log(
+			warnLevel,
+			fmt.Errorf("retrying the handler:%d:%d:%s:%w:%s", attempt, retry.attempts, meta, err, getFunctionName()),
+			data.Payload,
+			data.Client,
+		)
+		timer := time.NewTimer(retry.backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return d, err
+		case <-timer.C:
+		}
+		d, err = h.execute(cfg, f, ctx, meta, data)
+	}
+	return d, err
+}
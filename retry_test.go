@@ -0,0 +1,66 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryUntilTheHandlerSucceeds(t *testing.T) {
+	meta := WsFunc{Event: "flaky"}
+	logs := &logBuffer{}
+	calls := 0
+	h := newTestHandler(t, WithRetry(meta, 3, time.Millisecond)).
+		AddLogger(log.New(logs, "", 0)).
+		Handle(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			calls++
+			if calls < 3 {
+				return data, errors.New("transient")
+			}
+			return data, nil
+		})
+
+	if _, err := h.CallFunc(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("handler called %d times, want 3", calls)
+	}
+	if n := strings.Count(logs.String(), "retrying the handler"); n != 2 {
+		t.Fatalf("%d retries logged, want 2", n)
+	}
+}
+
+func TestRetryGivesUpAfterTheAttempts(t *testing.T) {
+	meta := WsFunc{Event: "broken"}
+	calls := 0
+	h := newTestHandler(t, WithRetry(meta, 2, 0)).Handle(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		calls++
+		return data, errors.New("permanent")
+	})
+	if _, err := h.CallFunc(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}}); err == nil {
+		t.Fatal("the failing call returned no error")
+	}
+	if calls != 2 {
+		t.Fatalf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestRetryStopsOnTheDoneContext(t *testing.T) {
+	meta := WsFunc{Event: "broken"}
+	h := newTestHandler(t, WithRetry(meta, 5, time.Second)).Handle(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, errors.New("permanent")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := h.CallFunc(ctx, meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}}); err == nil {
+		t.Fatal("the failing call returned no error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("the retries took %s after the context was done", elapsed)
+	}
+}
@@ -0,0 +1,74 @@
+package websockethandler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const logSamplingWindow = time.Minute
+
+// Emitting 1 in every n identical log entries within the window.
+// Entries are identical when they have the same level and event,
+// so a flood of one error does not hide others
+type logSampler struct {
+	mutex  sync.Mutex
+	n      int
+	start  time.Time
+	counts map[sampleKey]int
+}
+
+type sampleKey struct {
+	level level
+	event string
+}
+
+type sampleSummary struct {
+	level level
+	event string
+	count int
+}
+
+// Reports whether the entry is emitted, and the summaries of
+// suppressed entries when the window is over
+func (s *logSampler) sample(lvl level, event string) (bool, []sampleSummary) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var summaries []sampleSummary
+	now := time.Now()
+	if now.Sub(s.start) >= logSamplingWindow {
+		for key, count := range s.counts {
+			if suppressed := count - (count+s.n-1)/s.n; suppressed > 0 {
+				summaries = append(summaries, sampleSummary{level: key.level, event: key.event, count: suppressed})
+			}
+		}
+		s.counts = make(map[sampleKey]int)
+		s.start = now
+	}
+	key := sampleKey{level: lvl, event: event}
+	s.counts[key]++
+	return (s.counts[key]-1)%s.n == 0, summaries
+}
+
+// Setting the sampling of identical log entries, n <= 1 disables it
+func (h *wsHandler) SetLogSampling(n int) WsHandler {
+	if h.err == nil {
+		var sampler *logSampler
+		if n > 1 {
+			sampler = &logSampler{
+				n:      n,
+				start:  time.Now(),
+				counts: make(map[sampleKey]int),
+			}
+		}
+		h.logMutex.Lock()
+		h.sampler = sampler
+		h.logMutex.Unlock()
+		if sampler == nil {
+			return h
+		}
+		h.log(infoLevel,
+			fmt.Errorf("set log sampling to 1 in %d", n))
+	}
+	return h
+}
@@ -0,0 +1,69 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Setup and teardown around all stages of the pipeline
+type pipelineScope struct {
+	setup    func(ctx context.Context) (context.Context, error)
+	teardown func(ctx context.Context, err error)
+}
+
+// Setting the setup run by CallPipelineFunc before the first stage of the pipeline
+// of the root event, and the teardown run after the last stage.
+// The context returned by setup is shared by all stages, e.g. with a transaction.
+// Teardown always runs after a successful setup and gets the error of the first failed
+// stage, including timeout and panic, or nil. Either func may be nil
+func (h *wsHandler) HandlePipelineScope(rootMeta WsFunc, setup func(ctx context.Context) (context.Context, error), teardown func(ctx context.Context, err error)) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		rootMeta = h.normalize(rootMeta)
+		if _, ok := h.fun[rootMeta]; !ok {
+			h.err = fmt.Errorf("%w:%s:%s", ErrHandlerNotRegistered, rootMeta, getFunctionName())
+			return h
+		}
+		h.scopes[rootMeta] = pipelineScope{setup: setup, teardown: teardown}
+	}
+	return h
+}
+
+// Running the pipeline within the scope of the root event, if there is one
+func (h *wsHandler) runScoped(ctx context.Context, meta WsFunc, f *wsHandlerTree, data WsFuncData, ch chan MessagePayload, opts PipelineOptions) error {
+	scope, ok := h.scopes[meta]
+	if !ok {
+		err, _ := h.runStages(ctx, f, data, ch, opts)
+		return err
+	}
+	if scope.setup != nil {
+		var scoped context.Context
+		var err error
+		h.unlocked(func() {
+			scoped, err = scope.setup(ctx)
+		})
+		if err != nil {
+			err = fmt.Errorf("pipeline setup:%w:%s:%s", err, meta, getFunctionName())
+			// Shaped like the error payloads of the stages
+			payload := h.errorPayload(meta, data, err)
+			if payload.Code == "" {
+				payload.Code = ErrorCodeOf(err)
+			}
+			cfg := h.config()
+			h.unlocked(func() {
+				h.send(cfg, ctx, ch, payload)
+			})
+			return err
+		}
+		ctx = scoped
+	}
+	var failed error
+	if scope.teardown != nil {
+		defer h.unlocked(func() {
+			scope.teardown(ctx, failed)
+		})
+	}
+	err, failed := h.runStages(ctx, f, data, ch, opts)
+	return err
+}
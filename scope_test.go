@@ -0,0 +1,31 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFailedScopeSetupPayload(t *testing.T) {
+	meta := WsFunc{Event: "tx"}
+	h := newTestHandler(t).Handle(meta, stage("a")).
+		HandlePipelineScope(meta, func(ctx context.Context) (context.Context, error) {
+			return nil, errors.New("no connection")
+		}, nil)
+
+	out, err := h.CallPipelineFuncSync(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event, RequestID: "r1"}})
+	if err == nil {
+		t.Fatal("the failed setup returned no error")
+	}
+	if len(out) != 1 || out[0].Status != ErrorLevel || out[0].RequestID != "r1" {
+		t.Fatalf("outputs = %+v, want one error payload with the request id", out)
+	}
+
+	h.SetErrorFormatter(func(meta WsFunc, data WsFuncData, err error) MessagePayload {
+		return MessagePayload{Event: data.Payload.Event, RequestID: data.Payload.RequestID, Status: ErrorLevel, Data: "formatted"}
+	})
+	out, _ = h.CallPipelineFuncSync(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event, RequestID: "r2"}})
+	if len(out) != 1 || out[0].Data != "formatted" || out[0].RequestID != "r2" {
+		t.Fatalf("outputs = %+v, want the formatted payload", out)
+	}
+}
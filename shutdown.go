@@ -0,0 +1,70 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Calls in flight and the draining state of the handler
+type shutdown struct {
+	mutex    sync.Mutex
+	draining bool
+	active   int
+	drained  chan struct{}
+}
+
+// Stopping new calls and waiting for the calls in flight to complete
+// or for ctx to be done. New calls return ErrShuttingDown from then on.
+// The error of ctx is returned if the calls have not completed in time
+func (h *wsHandler) Shutdown(ctx context.Context) error {
+	h.shutdown.mutex.Lock()
+	if !h.shutdown.draining {
+		h.shutdown.draining = true
+		h.shutdown.drained = make(chan struct{})
+		if h.shutdown.active == 0 {
+			close(h.shutdown.drained)
+		}
+	}
+	drained := h.shutdown.drained
+	h.shutdown.mutex.Unlock()
+
+	select {
+	case <-drained:
+		h.log(
+			infoLevel,
+			fmt.Errorf("handler is drained:%s", getFunctionName()),
+		)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w:%s", ctx.Err(), getFunctionName())
+	}
+}
+
+// Counting the new call in flight, returns the error when the handler is draining
+func (h *wsHandler) enter(meta WsFunc) error {
+	h.shutdown.mutex.Lock()
+	defer h.shutdown.mutex.Unlock()
+	if h.shutdown.draining {
+		return fmt.Errorf("%w:%s:%s", ErrShuttingDown, meta, getFunctionName())
+	}
+	h.shutdown.active++
+	return nil
+}
+
+// Error payload of the call rejected by the draining handler
+func (h *wsHandler) shutdownPayload(meta WsFunc, data WsFuncData, err error) MessagePayload {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	payload := MessagePayload{Event: data.Payload.Event, RequestID: data.Payload.RequestID, Status: ErrorLevel, Data: ErrShuttingDown.Error()}
+	return h.formatError(meta, data, err, payload)
+}
+
+func (h *wsHandler) leave() {
+	h.shutdown.mutex.Lock()
+	defer h.shutdown.mutex.Unlock()
+	h.shutdown.active--
+	if h.shutdown.draining && h.shutdown.active == 0 {
+		close(h.shutdown.drained)
+	}
+}
@@ -0,0 +1,99 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Creating the handler logging to the slog logger.
+// The level of the entry is mapped onto slog.Level and the fields of the entry
+// are logged as attributes
+func NewHandlerSlog(logger *slog.Logger) WsHandler {
+	if logger == nil {
+		return NewHandler().AddLogger(nil)
+	}
+	return NewHandler().AddLogger(&slogLogger{logger: logger})
+}
+
+// Adapter of slog.Logger to stdLogger, entries other than strLog are logged as the message
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l level) slogLevel() slog.Level {
+	switch {
+	case l <= fatalLevel:
+		return slog.LevelError + 4
+	case l == errorLevel:
+		return slog.LevelError
+	case l == warnLevel:
+		return slog.LevelWarn
+	case l == infoLevel:
+		return slog.LevelInfo
+	case l == debugLevel:
+		return slog.LevelDebug
+	}
+	return slog.LevelDebug - 4
+}
+
+func (s *slogLogger) write(v ...interface{}) string {
+	if entry, ok := singleEntry(v); ok {
+		attrs := []slog.Attr{
+			slog.String("uuid", entry.UUID),
+			slog.String("lvl", entry.Level.String()),
+			slog.String("module", entry.Module),
+			slog.Any("body", entry.Body),
+		}
+		if entry.RequestID != "" {
+			attrs = append(attrs, slog.String("request_id", entry.RequestID))
+		}
+		if entry.File != "" {
+			attrs = append(attrs, slog.String("file", entry.File), slog.Int("line", entry.Line))
+		}
+		msg := fmt.Sprint(entry.Event)
+		s.logger.LogAttrs(context.Background(), entry.Level.slogLevel(), msg, attrs...)
+		return msg
+	}
+	msg := fmt.Sprint(v...)
+	s.logger.Info(msg)
+	return msg
+}
+
+func (s *slogLogger) Print(v ...interface{}) {
+	s.write(v...)
+}
+
+func (s *slogLogger) Printf(format string, v ...interface{}) {
+	s.write(fmt.Sprintf(format, v...))
+}
+
+func (s *slogLogger) Println(v ...interface{}) {
+	s.write(v...)
+}
+
+func (s *slogLogger) Fatal(v ...interface{}) {
+	s.write(v...)
+	os.Exit(1)
+}
+
+func (s *slogLogger) Fatalf(format string, v ...interface{}) {
+	s.Fatal(fmt.Sprintf(format, v...))
+}
+
+func (s *slogLogger) Fatalln(v ...interface{}) {
+	s.Fatal(v...)
+}
+
+func (s *slogLogger) Panic(v ...interface{}) {
+	panic(s.write(v...))
+}
+
+func (s *slogLogger) Panicf(format string, v ...interface{}) {
+	s.Panic(fmt.Sprintf(format, v...))
+}
+
+func (s *slogLogger) Panicln(v ...interface{}) {
+	s.Panic(v...)
+}
@@ -0,0 +1,103 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+type stagePositionCtx struct{}
+
+type stagePosition struct {
+	index int
+	total int
+}
+
+// Returns the position of the running pipeline stage, the index starts from zero.
+// Outside of a pipeline both values are zero
+func StagePositionFromContext(ctx context.Context) (index, total int) {
+	pos, ok := ctx.Value(stagePositionCtx{}).(stagePosition)
+	if !ok {
+		return 0, 0
+	}
+	return pos.index, pos.total
+}
+
+func withStagePosition(ctx context.Context, index, total int) context.Context {
+	return context.WithValue(ctx, stagePositionCtx{}, stagePosition{index: index, total: total})
+}
+
+// Number of stages from the node to the end of the longest branch
+func chainLength(node *wsHandlerTree) int {
+	depth := 0
+	for _, child := range node.children {
+		if d := chainLength(child); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
+// Behavior of the pipeline when a stage fails
+type PipelineErrorMode uint8
+
+const (
+	// The pipeline stops on the first failed stage
+	ModeFailFast PipelineErrorMode = iota
+	// All stages are run, the errors of the failed ones are joined
+	ModeCollect
+)
+
+// Setting the behavior of pipelines on failed stages
+func (h *wsHandler) SetPipelineErrorMode(mode PipelineErrorMode) WsHandler {
+	if h.err == nil {
+		if mode > ModeCollect {
+			h.err = fmt.Errorf("not a valid pipeline error mode:%d:%s", mode, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.pipelineErrorMode = mode
+	}
+	return h
+}
+
+// Options of a single pipeline call
+type PipelineOptions struct {
+	// The remaining stages are run after a failed stage, as in ModeCollect
+	ContinueOnError bool
+	// The children of a stage are run concurrently, each with its own stage timeout,
+	// and the next level starts after all of them are completed
+	Parallel bool
+	// Called before each stage runs with the position of StagePositionFromContext,
+	// concurrently for the children of a parallel stage
+	OnStage func(index int, total int, meta WsFunc)
+}
+
+// Calling an event in pipeline mode with the options of this call.
+// The payloads of failed stages are sent to the channel as well
+func (h *wsHandler) CallPipelineFuncWithOptions(ctx context.Context, meta WsFunc, data WsFuncData, ch chan MessagePayload, opts PipelineOptions) error {
+	return h.callPipeline(ctx, meta, data, ch, opts)
+}
+
+// Setting the stages run by CallPipelineFunc for events that are not registered,
+// instead of sending the error payload. No stages disable the default pipeline.
+// It takes precedence over the default handler, which is not run then.
+// The stages are recorded under the zero WsFunc, e.g. for LastError
+func (h *wsHandler) SetDefaultPipeline(stages ...HandlerFunc) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		var root, last *wsHandlerTree
+		for _, stage := range stages {
+			node := &wsHandlerTree{main: stage, parent: last}
+			if last == nil {
+				root = node
+			} else {
+				last.children = []*wsHandlerTree{node}
+			}
+			last = node
+		}
+		h.defaultPipeline = root
+	}
+	return h
+}
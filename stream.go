@@ -0,0 +1,199 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+const defaultStreamBufferSize = 8
+
+// Setting the buffer size of channels created internally by the streaming APIs.
+// A small buffer makes the pipeline wait for the reader after every stage,
+// a large one lets stages run ahead at the cost of memory held by unread payloads
+func (h *wsHandler) SetStreamBufferSize(n int) WsHandler {
+	if h.err == nil {
+		if n < 0 {
+			h.err = fmt.Errorf("not a valid stream buffer size:%d:%s", n, getFunctionName())
+			return h
+		}
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.streamBufferSize = n
+	}
+	return h
+}
+
+// Calling an event with the outputs delivered to the returned channel.
+// Events registered by HandleStream run the stream handler after the entry checks
+// of CallFunc and within the middleware, others are called in pipeline mode.
+// The channel is closed after the last output, the reader must drain it
+func (h *wsHandler) CallStreaming(ctx context.Context, meta WsFunc, data WsFuncData) <-chan MessagePayload {
+	h.mutex.RLock()
+	size := h.streamBufferSize
+	f, isStream := h.streams[h.lookupAlias(meta)]
+	cfg := h.config()
+	h.mutex.RUnlock()
+
+	ch := make(chan MessagePayload, size)
+	// The goroutine of a pipeline takes no slot of the budget, its stages take their own
+	release := h.counted()
+	if isStream {
+		var err error
+		if release, err = h.acquire(ctx, meta, cfg); err != nil {
+			h.log(
+				errorLevel,
+				fmt.Errorf("%w:%s", err, getFunctionName()),
+			)
+			close(ch)
+			return ch
+		}
+	}
+	go func() {
+		defer close(ch)
+		defer release()
+		// Panics of hooks running outside of the handlers, e.g. the context decorator
+		// or the pipeline scope, must not leave the reader without the closed channel
+		defer func() {
+			if r := recover(); r != nil {
+				h.log(
+					errorLevel,
+					fmt.Errorf("%w:%s:%s", PanicInfo{Value: r}, meta, getFunctionName()),
+					data.Payload,
+					data.Client,
+					string(debug.Stack()),
+				)
+			}
+		}()
+		var err error
+		if isStream {
+			err = h.callStream(ctx, meta, f, data, ch)
+		} else {
+			err = h.CallPipelineFunc(ctx, meta, data, ch)
+		}
+		if err != nil {
+			h.log(
+				errorLevel,
+				fmt.Errorf("%w:%s", err, getFunctionName()),
+			)
+		}
+	}()
+	return ch
+}
+
+// Handler sending any number of outputs through the emitter.
+// Returning an error terminates the stream
+type StreamHandlerFunc func(ctx context.Context, data WsFuncData, emit *Emitter) error
+
+// Sending of stream outputs to the reader.
+// There are two error channels: EmitError delivers a per-item error
+// and the stream goes on, an error returned by the handler ends the stream
+type Emitter struct {
+	ctx  context.Context
+	data WsFuncData
+	ch   chan<- MessagePayload
+}
+
+// Sending the output, the error is returned when the stream context is done
+func (e *Emitter) Emit(payload MessagePayload) error {
+	select {
+	case e.ch <- payload:
+		return nil
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	}
+}
+
+// Sending a per-item error payload without ending the stream
+func (e *Emitter) EmitError(err error) error {
+	return e.Emit(MessagePayload{
+		Event:  e.data.Payload.Event,
+		Status: ErrorLevel,
+		Data:   err.Error(),
+	})
+}
+
+// Registration of the stream handler called by CallStreaming
+func (h *wsHandler) HandleStream(meta WsFunc, f StreamHandlerFunc) WsHandler {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	meta = h.normalize(meta)
+	if h.isRegistered(meta) {
+		h.duplicates = append(h.duplicates, meta)
+		if h.err == nil {
+			h.err = fmt.Errorf("%w:%s:%s", ErrAlreadyRegistered, meta, getFunctionName())
+		}
+		return h
+	}
+	if _, ok := h.memo[meta]; ok && h.err == nil {
+		h.err = fmt.Errorf("a stream event can not be memoized:%s:%s", meta, getFunctionName())
+		return h
+	}
+	if h.err == nil {
+		h.streams[meta] = f
+	}
+	return h
+}
+
+func (h *wsHandler) callStream(ctx context.Context, meta WsFunc, f StreamHandlerFunc, data WsFuncData, ch chan<- MessagePayload) error {
+	if err := h.enter(meta); err != nil {
+		ch <- h.shutdownPayload(meta, data, err)
+		return err
+	}
+	defer h.leave()
+	ctx, release := h.trackCancel(ctx)
+	defer release()
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	meta = h.resolveAlias(meta)
+	ctx = h.decorate(ctx, data)
+	h.log(
+		debugLevel,
+		fmt.Errorf("in:%s:%v:%s", meta, data, getFunctionName()),
+	)
+	if payload, err := h.admit(meta, data); err != nil {
+		h.unlocked(func() {
+			ch <- payload
+		})
+		return err
+	}
+	// The middleware of the event runs around the stream handler like around any other handler
+	run := h.wrap(meta, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		return data, h.invokeStream(ctx, meta, f, data, &Emitter{ctx: ctx, data: data, ch: ch})
+	})
+	var err error
+	h.unlocked(func() {
+		_, err = run(ctx, data)
+	})
+	if err != nil {
+		h.lastErrors.set(meta, err)
+		if onError := h.onError; onError != nil {
+			h.unlocked(func() {
+				onError(meta, data, err)
+			})
+		}
+		// The final error payload is sent even if the context is done, the reader drains the channel
+		h.unlocked(func() {
+			ch <- MessagePayload{Event: data.Payload.Event, Status: ErrorLevel, Data: err.Error()}
+		})
+		return fmt.Errorf("%w:%s:%s", err, meta, getFunctionName())
+	}
+	return nil
+}
+
+func (h *wsHandler) invokeStream(ctx context.Context, meta WsFunc, f StreamHandlerFunc, data WsFuncData, emitter *Emitter) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			info := PanicInfo{Value: r, Stack: debug.Stack()}
+			h.log(
+				errorLevel,
+				fmt.Errorf("%w:%s:%s", info, meta, getFunctionName()),
+				data.Payload,
+				data.Client,
+				string(info.Stack),
+			)
+			err = info
+		}
+	}()
+	return f(ctx, data, emitter)
+}
@@ -0,0 +1,120 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func ticks(ctx context.Context, data WsFuncData, emit *Emitter) error {
+	return emit.Emit(MessagePayload{Event: data.Payload.Event, Data: "tick"})
+}
+
+func drain(ch <-chan MessagePayload) []MessagePayload {
+	var out []MessagePayload
+	for p := range ch {
+		out = append(out, p)
+	}
+	return out
+}
+
+func TestStreamRunsTheEntryChecks(t *testing.T) {
+	meta := WsFunc{Event: "ticks"}
+	data := WsFuncData{Client: "c1", Payload: MessagePayload{Event: meta.Event}}
+
+	h := newTestHandler(t).HandleStream(meta, ticks).SetReady(meta, false)
+	out := drain(h.CallStreaming(context.Background(), meta, data))
+	if len(out) != 1 || out[0].Status != ErrorLevel || out[0].Data != ErrNotReady.Error() {
+		t.Fatalf("not ready stream = %+v, want the not ready payload", out)
+	}
+
+	h = newTestHandler(t).HandleStream(meta, ticks).SetClientQuota(1, time.Minute, func(d WsFuncData) string { return "c1" })
+	drain(h.CallStreaming(context.Background(), meta, data))
+	out = drain(h.CallStreaming(context.Background(), meta, data))
+	if len(out) != 1 || out[0].Data != ErrQuotaExceeded.Error() {
+		t.Fatalf("stream over the quota = %+v, want the quota payload", out)
+	}
+
+	h = newTestHandler(t).HandleStream(meta, ticks).SetRateLimit(0.001, 1).SetClientIdentity(func(client interface{}) string { return "c1" })
+	drain(h.CallStreaming(context.Background(), meta, data))
+	out = drain(h.CallStreaming(context.Background(), meta, data))
+	if len(out) != 1 || out[0].Data != ErrRateLimited.Error() {
+		t.Fatalf("stream over the rate limit = %+v, want the rate limit payload", out)
+	}
+}
+
+func TestStreamRunsWithinMiddleware(t *testing.T) {
+	meta := WsFunc{Event: "ticks"}
+	h := newTestHandler(t).HandleStream(meta, ticks)
+	h.UseFor(meta, func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+			if data.Client == nil {
+				return data, errors.New("anonymous")
+			}
+			return next(ctx, data)
+		}
+	})
+
+	out := drain(h.CallStreaming(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}}))
+	if len(out) != 1 || out[0].Status != ErrorLevel {
+		t.Fatalf("rejected stream = %+v, want one error payload", out)
+	}
+	out = drain(h.CallStreaming(context.Background(), meta, WsFuncData{Client: "c1", Payload: MessagePayload{Event: meta.Event}}))
+	if len(out) != 1 || out[0].Data != "tick" {
+		t.Fatalf("stream = %+v, want one tick", out)
+	}
+}
+
+func TestStreamPanicEndsTheStream(t *testing.T) {
+	meta := WsFunc{Event: "broken"}
+	h := newTestHandler(t).HandleStream(meta, func(ctx context.Context, data WsFuncData, emit *Emitter) error {
+		if err := emit.Emit(MessagePayload{Event: meta.Event, Data: "first"}); err != nil {
+			return err
+		}
+		panic("stream failed")
+	})
+
+	ch := h.CallStreaming(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}})
+	var out []MessagePayload
+	timeout := time.After(time.Second)
+	for closed := false; !closed; {
+		select {
+		case p, ok := <-ch:
+			if !ok {
+				closed = true
+				break
+			}
+			out = append(out, p)
+		case <-timeout:
+			t.Fatal("the channel is not closed after the panic")
+		}
+	}
+	if len(out) != 2 || out[0].Data != "first" {
+		t.Fatalf("outputs = %+v, want the first output and the error", out)
+	}
+	if out[1].Status != ErrorLevel || out[1].Data != (PanicInfo{Value: "stream failed"}).Error() {
+		t.Fatalf("last output = %+v, want the error payload of the panic", out[1])
+	}
+}
+
+func TestStreamHookPanicClosesTheChannel(t *testing.T) {
+	meta := WsFunc{Event: "ticks"}
+	h := newTestHandler(t).HandleStream(meta, ticks).
+		SetContextDecorator(func(ctx context.Context, data WsFuncData) context.Context {
+			panic("decorator failed")
+		})
+
+	done := make(chan []MessagePayload)
+	go func() {
+		done <- drain(h.CallStreaming(context.Background(), meta, WsFuncData{Payload: MessagePayload{Event: meta.Event}}))
+	}()
+	select {
+	case out := <-done:
+		if len(out) != 0 {
+			t.Fatalf("outputs = %+v, want none", out)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel is not closed after the panic of the decorator")
+	}
+}
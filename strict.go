@@ -0,0 +1,41 @@
+package websockethandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Panics listing all duplicate registrations, if there were any.
+// Intended as a fail-fast checkpoint at the end of the startup registration
+func (h *wsHandler) MustBeUnique() WsHandler {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if errors.Is(h.err, ErrAlreadyRegistered) || len(h.duplicates) > 0 {
+		list := make([]string, 0, len(h.duplicates))
+		for _, meta := range h.duplicates {
+			list = append(list, meta.String())
+		}
+		panic(fmt.Errorf("%w:%s:%s", ErrAlreadyRegistered, strings.Join(list, ","), getFunctionName()))
+	}
+	return h
+}
+
+// Panics with the latched error, if any
+func (h *wsHandler) PanicOnError() WsHandler {
+	if h.err != nil {
+		panic(fmt.Errorf("%w:%s", h.err, getFunctionName()))
+	}
+	return h
+}
+
+func (h *wsHandler) isRegistered(meta WsFunc) bool {
+	if _, ok := h.fun[meta]; ok {
+		return true
+	}
+	if _, ok := h.multi[meta]; ok {
+		return true
+	}
+	_, ok := h.streams[meta]
+	return ok
+}
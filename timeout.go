@@ -0,0 +1,42 @@
+package websockethandler
+
+import (
+	"context"
+	"time"
+)
+
+type callTimeoutCtx struct{}
+
+// Overriding the timeout of the handlers run by this call: the call of CallFunc
+// and every stage of a pipeline. Zero runs them within the deadline of ctx as is,
+// even if the pipeline timeout of WithPipelineTimeout is set
+func WithCallTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d < 0 {
+		d = 0
+	}
+	return context.WithValue(ctx, callTimeoutCtx{}, d)
+}
+
+// Timeout of a handler run by the call, the one of WithCallTimeout wins
+// over the pipeline timeout of the handler. CallFunc has no timeout by default.
+// Must be called under the read lock
+func (h *wsHandler) callTimeout(ctx context.Context, pipeline bool) time.Duration {
+	if h.testMode {
+		return 0
+	}
+	if d, ok := ctx.Value(callTimeoutCtx{}).(time.Duration); ok {
+		return d
+	}
+	if pipeline {
+		return h.pipelineTimeout
+	}
+	return 0
+}
+
+// Applying the timeout of the call to ctx, zero leaves ctx as is
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,83 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Handler recording the deadline of its context
+func deadlineOf(got chan<- time.Time) HandlerFunc {
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		deadline, _ := ctx.Deadline()
+		got <- deadline
+		return data, nil
+	}
+}
+
+func TestCallTimeoutWinsOverThePipelineTimeout(t *testing.T) {
+	h := newTestHandler(t, WithPipelineTimeout(time.Second)).Handle(WsFunc{Event: "blocked"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		<-ctx.Done()
+		return data, ctx.Err()
+	})
+	ctx := WithCallTimeout(context.Background(), 10*time.Millisecond)
+	start := time.Now()
+	_, err := h.CallPipelineFuncSync(ctx, WsFunc{Event: "blocked"}, WsFuncData{Payload: MessagePayload{Event: "blocked"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the deadline of the call timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("the stage ran %s, the pipeline timeout has won", elapsed)
+	}
+
+	h = newTestHandler(t, WithPipelineTimeout(10*time.Millisecond)).Handle(WsFunc{Event: "slow"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		time.Sleep(50 * time.Millisecond)
+		return data, ctx.Err()
+	})
+	ctx = WithCallTimeout(context.Background(), time.Second)
+	if _, err := h.CallPipelineFuncSync(ctx, WsFunc{Event: "slow"}, WsFuncData{Payload: MessagePayload{Event: "slow"}}); err != nil {
+		t.Fatalf("stage within the call timeout: %v", err)
+	}
+}
+
+func TestZeroCallTimeoutUsesTheContextAsIs(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	parent, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	ctx := WithCallTimeout(parent, 0)
+	got := make(chan time.Time, 1)
+	h := newTestHandler(t, WithPipelineTimeout(10*time.Millisecond)).Handle(WsFunc{Event: "root"}, deadlineOf(got))
+
+	if _, err := h.CallFunc(ctx, WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if d := <-got; !d.Equal(deadline) {
+		t.Fatalf("deadline of CallFunc = %v, want the one of the caller %v", d, deadline)
+	}
+	if _, err := h.CallPipelineFuncSync(ctx, WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}); err != nil {
+		t.Fatalf("call pipeline: %v", err)
+	}
+	if d := <-got; !d.Equal(deadline) {
+		t.Fatalf("deadline of the stage = %v, want the one of the caller %v", d, deadline)
+	}
+}
+
+func TestPipelineTimeoutAppliesWithoutTheCallTimeout(t *testing.T) {
+	got := make(chan time.Time, 1)
+	h := newTestHandler(t, WithPipelineTimeout(time.Minute)).Handle(WsFunc{Event: "root"}, deadlineOf(got))
+
+	if _, err := h.CallFunc(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if d := <-got; !d.IsZero() {
+		t.Fatalf("deadline of CallFunc = %v, want none", d)
+	}
+	start := time.Now()
+	if _, err := h.CallPipelineFuncSync(context.Background(), WsFunc{Event: "root"}, WsFuncData{Payload: MessagePayload{Event: "root"}}); err != nil {
+		t.Fatalf("call pipeline: %v", err)
+	}
+	if d := <-got; d.Before(start.Add(59*time.Second)) || d.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("deadline of the stage = %v, want the pipeline timeout", d)
+	}
+}
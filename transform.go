@@ -0,0 +1,42 @@
+package websockethandler
+
+// Function applied to every handler output before it is returned
+type ResponseTransformer func(meta WsFunc, data WsFuncData) WsFuncData
+
+// Setting the transformer of handler outputs, nil disables it
+func (h *wsHandler) SetResponseTransformer(t ResponseTransformer) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.transformer = t
+	}
+	return h
+}
+
+// Excluding the outputs of the event from the response transformer,
+// e.g. for already redacted or binary passthrough handlers
+func (h *wsHandler) NoTransform(meta WsFunc) WsHandler {
+	if h.err == nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		meta = h.normalize(meta)
+		h.noTransform[meta] = struct{}{}
+	}
+	return h
+}
+
+// Transformer of the outputs of the event, nil if there is none.
+// Must be called under the read lock, the transformer is applied without it
+func (h *wsHandler) transformerOf(meta WsFunc) ResponseTransformer {
+	if _, ok := h.noTransform[meta]; ok {
+		return nil
+	}
+	return h.transformer
+}
+
+func transform(t ResponseTransformer, meta WsFunc, data WsFuncData) WsFuncData {
+	if t == nil {
+		return data
+	}
+	return t(meta, data)
+}
@@ -0,0 +1,38 @@
+package websockethandler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Wrapping the strongly typed func into the handler.
+// Payload.Data is decoded into In, the returned Out becomes Data of the response.
+// A decode error returns the error payload with CodeInvalid without calling fn
+func TypedHandler[In any, Out any](fn func(context.Context, In) (Out, error)) HandlerFunc {
+	return func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		failed := WsFuncData{
+			Client:  data.Client,
+			Payload: MessagePayload{Event: data.Payload.Event, Status: ErrorLevel},
+		}
+		var in In
+		if err := data.Payload.DecodeData(&in); err != nil {
+			failed.Payload.Data = err.Error()
+			return failed, CodedError{Code: CodeInvalid, Err: fmt.Errorf("%w:%s", err, getFunctionName())}
+		}
+		out, err := fn(ctx, in)
+		if err != nil {
+			return failed, err
+		}
+		return WsFuncData{
+			Client:  data.Client,
+			Payload: NewMessagePayload(data.Payload.Event, out),
+		}, nil
+	}
+}
+
+// Returning the client of the call as T.
+// False is returned for a nil client or a client of another type
+func ClientAs[T any](data WsFuncData) (T, bool) {
+	client, ok := data.Client.(T)
+	return client, ok
+}
@@ -0,0 +1,62 @@
+package websockethandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	h := newTestHandler(t).Handle(WsFunc{Event: "greet"}, TypedHandler(func(ctx context.Context, in greeting) (string, error) {
+		return "hello " + in.Name, nil
+	}))
+	out, err := h.CallFunc(context.Background(), WsFunc{Event: "greet"}, WsFuncData{Payload: MessagePayload{Event: "greet", Data: map[string]interface{}{"name": "ann"}}})
+	if err != nil || out.Payload.Data != "hello ann" || out.Payload.Event != "greet" {
+		t.Fatalf("greet = %+v, %v, want hello ann", out.Payload, err)
+	}
+}
+
+func TestTypedHandlerDecodeError(t *testing.T) {
+	called := false
+	f := TypedHandler(func(ctx context.Context, in greeting) (string, error) {
+		called = true
+		return "", nil
+	})
+	out, err := f(context.Background(), WsFuncData{Payload: MessagePayload{Event: "greet", Data: "not an object"}})
+	if err == nil || ErrorCodeOf(err) != CodeInvalid {
+		t.Fatalf("err = %v, want CodeInvalid", err)
+	}
+	if out.Payload.Status != ErrorLevel || called {
+		t.Fatalf("payload = %+v, called = %v, want the error payload without the call", out.Payload, called)
+	}
+}
+
+func TestTypedHandlerError(t *testing.T) {
+	failure := errors.New("unknown name")
+	f := TypedHandler(func(ctx context.Context, in greeting) (string, error) {
+		return "", failure
+	})
+	out, err := f(context.Background(), WsFuncData{Payload: MessagePayload{Event: "greet", Data: greeting{Name: "bob"}}})
+	if !errors.Is(err, failure) || out.Payload.Status != ErrorLevel {
+		t.Fatalf("greet = %+v, %v, want the error of fn", out.Payload, err)
+	}
+}
+
+// Client of the connection in the calls
+type session struct{ user string }
+
+func TestClientAs(t *testing.T) {
+	if client, ok := ClientAs[*session](WsFuncData{Client: &session{user: "ann"}}); !ok || client.user != "ann" {
+		t.Fatalf("ClientAs = %v, %v, want the session of ann", client, ok)
+	}
+	if client, ok := ClientAs[*session](WsFuncData{}); ok || client != nil {
+		t.Fatalf("ClientAs of a nil client = %v, %v, want nil, false", client, ok)
+	}
+	if client, ok := ClientAs[*session](WsFuncData{Client: "ann"}); ok || client != nil {
+		t.Fatalf("ClientAs of a string client = %v, %v, want nil, false", client, ok)
+	}
+}
@@ -1,8 +1,10 @@
 package websockethandler
 
 import (
+	"reflect"
 	"runtime"
 	"strings"
+	"unsafe"
 )
 
 func getFunctionName() string {
@@ -11,3 +13,25 @@ func getFunctionName() string {
 	funcName := strings.Split(fullFuncName, "/")
 	return funcName[len(funcName)-1]
 }
+
+func getHandlerName(f HandlerFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	funcName := strings.Split(fn.Name(), "/")
+	return funcName[len(funcName)-1]
+}
+
+// Identity of the function value, the pointer to its closure.
+// Every closure created at run time is a distinct value, so closures of the same
+// function literal, e.g. made by a factory or by TypedHandler, are different functions.
+// A function without captured variables is the same value wherever it is used
+type funcKey uintptr
+
+func keyOf(f HandlerFunc) funcKey {
+	return *(*funcKey)(unsafe.Pointer(&f))
+}
+
+// Id of the pipeline tree node, assigned once to every function added to the tree
+type handlerID uint64
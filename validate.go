@@ -0,0 +1,41 @@
+package websockethandler
+
+import "fmt"
+
+// Check of the input run before the handler, an error rejects the call
+type Validator func(WsFuncData) error
+
+// Registering the function like Handle with the validator of its input.
+// A rejected call does not run the handler and returns the error payload
+// with the validation message and CodeInvalid
+func (h *wsHandler) HandleValidated(meta WsFunc, f HandlerFunc, v Validator, parent ...HandlerFunc) WsHandler {
+	h.Handle(meta, f, parent...)
+	if h.err == nil && v != nil {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.validators[h.normalize(meta)] = v
+	}
+	return h
+}
+
+// Logging the rejected input and building the error payload.
+// Must be called under the read lock
+func (h *wsHandler) rejected(meta WsFunc, data WsFuncData, err error) (WsFuncData, error) {
+	h.log(
+		warnLevel,
+		fmt.Errorf("validation failed:%s:%w:%s", meta, err, getFunctionName()),
+		data.Payload,
+		data.Client,
+	)
+	coded := CodedError{Code: CodeInvalid, Err: err}
+	return WsFuncData{
+		Client: data.Client,
+		Payload: h.formatError(meta, data, coded, MessagePayload{
+			Event:     data.Payload.Event,
+			RequestID: data.Payload.RequestID,
+			Status:    ErrorLevel,
+			Code:      CodeInvalid,
+			Data:      err.Error(),
+		}),
+	}, coded
+}
@@ -0,0 +1,72 @@
+package websockethandler
+
+import (
+	"context"
+	"time"
+)
+
+// Goroutines running the handlers of all events, see WithWorkers
+type workerPool struct {
+	jobs chan func()
+	size int
+}
+
+func newWorkerPool(n int) *workerPool {
+	p := &workerPool{jobs: make(chan func()), size: n}
+	for i := 0; i < n; i++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Running every handler on one of n goroutines, so that no more than n handlers
+// of all events run at once. A call beyond the capacity waits for a free worker
+// until its ctx is done. A handler discarded after a timeout keeps its worker
+// until it returns. A call made by a handler with its ctx does not wait for a worker,
+// it runs on a new goroutine while the worker of the caller waits for it,
+// a call made with another ctx waits and may deadlock a full pool.
+// The workers live as long as the process, n <= 0 disables the pool
+func WithWorkers(n int) Option {
+	return func(h *wsHandler) {
+		h.workers = nil
+		if n > 0 {
+			h.workers = newWorkerPool(n)
+		}
+	}
+}
+
+type workerCtx struct{}
+
+// Marking the context of the handler run on a worker of the pool
+func withWorker(ctx context.Context, p *workerPool) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, workerCtx{}, p)
+}
+
+// Running the job on a worker, or on a new goroutine without the pool
+// and for a call made by a handler already running on a worker.
+// Returns false when ctx is done before a worker is free
+func (h *wsHandler) spawn(cfg callConfig, ctx context.Context, meta WsFunc, job func()) bool {
+	if cfg.workers == nil {
+		go job()
+		return true
+	}
+	if p, _ := ctx.Value(workerCtx{}).(*workerPool); p == cfg.workers {
+		go job()
+		return true
+	}
+	start := time.Now()
+	select {
+	case cfg.workers.jobs <- job:
+		h.observeQueueWait(cfg, meta, time.Since(start))
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package websockethandler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkersBoundTheRunningHandlers(t *testing.T) {
+	h := newTestHandler(t, WithWorkers(2))
+	var running, peak int64
+	h.Handle(WsFunc{Event: "busy"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return data, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.CallFunc(context.Background(), WsFunc{Event: "busy"}, WsFuncData{Payload: MessagePayload{Event: "busy"}}); err != nil {
+				t.Errorf("call: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Fatalf("%d handlers ran at once, want at most 2", peak)
+	}
+	if s := h.Stats(); s.Workers != 2 {
+		t.Fatalf("Stats().Workers = %d, want 2", s.Workers)
+	}
+}
+
+func TestNestedCallsDoNotDeadlockFullPool(t *testing.T) {
+	const workers = 2
+	h := newTestHandler(t, WithWorkers(workers))
+	h.Handle(WsFunc{Event: "inner"}, stage("i"))
+	var entered sync.WaitGroup
+	entered.Add(workers)
+	h.Handle(WsFunc{Event: "outer"}, func(ctx context.Context, data WsFuncData) (WsFuncData, error) {
+		// All workers are taken by the outer calls before the nested ones start
+		entered.Done()
+		entered.Wait()
+		return h.CallFunc(ctx, WsFunc{Event: "inner"}, WsFuncData{Payload: MessagePayload{Event: "inner"}})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := h.CallFunc(ctx, WsFunc{Event: "outer"}, WsFuncData{Payload: MessagePayload{Event: "outer"}})
+			if err != nil || out.Payload.Data != "i" {
+				t.Errorf("outer call = %v, %v, want Data i", out.Payload.Data, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
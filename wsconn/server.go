@@ -0,0 +1,216 @@
+// Package wsconn serves websockethandler events over gorilla/websocket connections
+package wsconn
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bydanovm/websockethandler"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultReadTimeout  = 60 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	defaultBufferSize   = 16
+)
+
+// Server upgrading HTTP requests to websocket connections.
+// Every frame is decoded into a MessagePayload by the Codec of the handler and called
+// by CallFunc with the *Conn as the Client, the result is encoded back by the Codec.
+// The frames are text for JSONCodec and binary for other codecs.
+// The connection is registered as a broadcast client for its lifetime,
+// the OnConnect and OnDisconnect hooks of the handler are called with the *Conn
+type Server struct {
+	Handler  websockethandler.WsHandler
+	Upgrader websocket.Upgrader
+	// Read deadline, extended before every frame and by every pong, 60 seconds by default
+	ReadTimeout time.Duration
+	// Interval of pings, 9/10 of ReadTimeout by default
+	PingInterval time.Duration
+	// Deadline of every write, 10 seconds by default
+	WriteTimeout time.Duration
+	// Maximum size of an inbound frame, 0 means no limit
+	ReadLimit int64
+	// Size of the outbound queue of the connection, 16 by default
+	BufferSize int
+}
+
+// Creating the server with the default settings
+func NewServer(h websockethandler.WsHandler) *Server {
+	return &Server{Handler: h}
+}
+
+// Connection served by the Server, the Client of the handler calls
+type Conn struct {
+	// Id of the broadcast client
+	ID  string
+	ws  *websocket.Conn
+	out chan websockethandler.MessagePayload
+	ctx context.Context
+}
+
+// Id of the broadcast client, used by NotifyDisconnect
+func (c *Conn) ClientID() string {
+	return c.ID
+}
+
+// Queueing the payload to the connection.
+// Returns false when the connection is closed
+func (c *Conn) Send(payload websockethandler.MessagePayload) bool {
+	select {
+	case c.out <- payload:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// The underlying websocket connection, e.g. for the remote address.
+// Writes must go through Send
+func (c *Conn) WebSocket() *websocket.Conn {
+	return c.ws
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.ServeWS(w, r)
+}
+
+// Upgrading the request and serving the connection until it is closed
+func (s *Server) ServeWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has replied with the error
+		return
+	}
+	defer ws.Close()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	c := &Conn{
+		ID:  uuid.NewString(),
+		ws:  ws,
+		out: make(chan websockethandler.MessagePayload, s.bufferSize()),
+		ctx: ctx,
+	}
+	s.Handler.RegisterClient(c.ID, c.out)
+	s.Handler.NotifyConnect(c)
+	defer s.Handler.NotifyDisconnect(c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.writeLoop(c)
+		// Unblocking the read loop after a failed write
+		ws.Close()
+	}()
+	s.readLoop(c)
+	cancel()
+	<-done
+}
+
+func (s *Server) readLoop(c *Conn) {
+	if s.ReadLimit > 0 {
+		c.ws.SetReadLimit(s.ReadLimit)
+	}
+	timeout := s.readTimeout()
+	codec := s.Handler.Codec()
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(timeout))
+	})
+	for {
+		// Extended before every read, so that a handler slower than the timeout
+		// does not expire the deadline while the pongs wait to be read
+		c.ws.SetReadDeadline(time.Now().Add(timeout))
+		_, frame, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		payload, err := codec.Unmarshal(frame)
+		if err != nil {
+			c.Send(websockethandler.MessagePayload{
+				Status: websockethandler.ErrorLevel,
+				Code:   websockethandler.CodeInvalid,
+				Data:   err.Error(),
+			})
+			continue
+		}
+		meta := websockethandler.WsFunc{Event: payload.Event, Status: payload.Status}
+		d, _ := s.Handler.CallFunc(c.ctx, meta, websockethandler.WsFuncData{Client: c, Payload: payload})
+		if d.Payload.Broadcast {
+			// Delivered to all registered clients, this one included
+			continue
+		}
+		target := c
+		if t, ok := d.Payload.TargetClient.(*Conn); ok && t != nil {
+			target = t
+		}
+		target.Send(d.Payload)
+	}
+}
+
+func (s *Server) writeLoop(c *Conn) {
+	ticker := time.NewTicker(s.pingInterval())
+	defer ticker.Stop()
+	codec := s.Handler.Codec()
+	frameType := websocket.BinaryMessage
+	if _, ok := codec.(websockethandler.JSONCodec); ok {
+		frameType = websocket.TextMessage
+	}
+	for {
+		select {
+		case payload := <-c.out:
+			frame, err := codec.Marshal(payload)
+			if err != nil {
+				// The payload can not be delivered, the connection is kept
+				continue
+			}
+			c.ws.SetWriteDeadline(time.Now().Add(s.writeTimeout()))
+			if err := c.ws.WriteMessage(frameType, frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.writeTimeout())); err != nil {
+				return
+			}
+		case <-c.ctx.Done():
+			c.ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(s.writeTimeout()),
+			)
+			return
+		}
+	}
+}
+
+func (s *Server) readTimeout() time.Duration {
+	if s.ReadTimeout > 0 {
+		return s.ReadTimeout
+	}
+	return defaultReadTimeout
+}
+
+func (s *Server) pingInterval() time.Duration {
+	if s.PingInterval > 0 {
+		return s.PingInterval
+	}
+	return s.readTimeout() * 9 / 10
+}
+
+func (s *Server) writeTimeout() time.Duration {
+	if s.WriteTimeout > 0 {
+		return s.WriteTimeout
+	}
+	return defaultWriteTimeout
+}
+
+func (s *Server) bufferSize() int {
+	if s.BufferSize > 0 {
+		return s.BufferSize
+	}
+	return defaultBufferSize
+}
@@ -0,0 +1,46 @@
+package wsconn
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bydanovm/websockethandler"
+	"github.com/gorilla/websocket"
+)
+
+func TestHandlerSlowerThanTheReadTimeoutKeepsTheConnection(t *testing.T) {
+	h := websockethandler.NewHandler().AddLogger(log.New(io.Discard, "", 0)).
+		Handle(websockethandler.WsFunc{Event: "slow"}, func(ctx context.Context, data websockethandler.WsFuncData) (websockethandler.WsFuncData, error) {
+			time.Sleep(300 * time.Millisecond)
+			data.Payload.Data = "done"
+			return data, nil
+		})
+	s := NewServer(h)
+	s.ReadTimeout = 100 * time.Millisecond
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 2; i++ {
+		if err := ws.WriteJSON(websockethandler.MessagePayload{Event: "slow"}); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+		var out websockethandler.MessagePayload
+		if err := ws.ReadJSON(&out); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+		if out.Data != "done" {
+			t.Fatalf("response %d = %+v, want Data done", i, out)
+		}
+	}
+}
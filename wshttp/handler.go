@@ -0,0 +1,35 @@
+// Package wshttp allows calling websockethandler events over plain HTTP for debugging
+package wshttp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bydanovm/websockethandler"
+)
+
+// Wrapping the event into an HTTP handler.
+// The request body is decoded into a MessagePayload, the request itself
+// is passed as the Client and the result of CallFunc is written as JSON.
+// A failed call responds with the HTTP status of its error code
+func AsHTTPHandler(h websockethandler.WsHandler, meta websockethandler.WsFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var payload websockethandler.MessagePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		d, err := h.CallFunc(r.Context(), meta, websockethandler.WsFuncData{Client: r, Payload: payload})
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(websockethandler.ErrorCodeOf(err).HTTPStatus())
+		}
+		json.NewEncoder(w).Encode(d.Payload)
+	}
+}
@@ -0,0 +1,57 @@
+// Package wstest provides helpers for testing and benchmarking websockethandler handlers
+package wstest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bydanovm/websockethandler"
+)
+
+// Running the registered pipeline b.N times in a row.
+// Stage outputs are drained by a separate goroutine so that
+// the pipeline never blocks on the channel, ns/op and allocs are reported.
+// The stage timeouts are disabled, so that a stage slowed down by the load
+// is measured instead of failing the run. For the durations reported
+// by the handler, create it WithClock of a FakeClock
+func BenchmarkPipeline(h websockethandler.WsHandler, meta websockethandler.WsFunc, data websockethandler.WsFuncData, b *testing.B) {
+	ch := make(chan websockethandler.MessagePayload, 16)
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	defer func() {
+		close(ch)
+		<-done
+	}()
+
+	ctx := websockethandler.WithCallTimeout(context.Background(), 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := h.CallPipelineFunc(ctx, meta, data, ch); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+}
+
+// Calling the registered event b.N times from parallel goroutines,
+// e.g. to compare the throughput with and without WithWorkers.
+// ns/op and allocs are reported, the timeouts are disabled like in BenchmarkPipeline
+func BenchmarkCallParallel(h websockethandler.WsHandler, meta websockethandler.WsFunc, data websockethandler.WsFuncData, b *testing.B) {
+	ctx := websockethandler.WithCallTimeout(context.Background(), 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := h.CallFunc(ctx, meta, data); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+}
@@ -0,0 +1,77 @@
+package wstest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/bydanovm/websockethandler"
+)
+
+func TestBenchmarkPipelineIgnoresStageTimeouts(t *testing.T) {
+	h := websockethandler.NewHandler(websockethandler.WithPipelineTimeout(time.Millisecond)).
+		AddLogger(log.New(io.Discard, "", 0)).
+		Handle(websockethandler.WsFunc{Event: "slow"}, func(ctx context.Context, data websockethandler.WsFuncData) (websockethandler.WsFuncData, error) {
+			select {
+			case <-time.After(5 * time.Millisecond):
+				return data, nil
+			case <-ctx.Done():
+				return data, ctx.Err()
+			}
+		})
+	data := websockethandler.WsFuncData{Payload: websockethandler.MessagePayload{Event: "slow"}}
+	r := testing.Benchmark(func(b *testing.B) {
+		BenchmarkPipeline(h, websockethandler.WsFunc{Event: "slow"}, data, b)
+	})
+	if r.N == 0 {
+		t.Fatal("the benchmark failed on the stage timeout")
+	}
+}
+
+func TestFakeClockDrivesTheHandlerDurations(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var entries []websockethandler.AccessLogEntry
+	h := websockethandler.NewHandler(websockethandler.WithClock(clock.Now)).
+		AddLogger(log.New(io.Discard, "", 0)).
+		SetAccessLogger(func(e websockethandler.AccessLogEntry) { entries = append(entries, e) }).
+		Handle(websockethandler.WsFunc{Event: "tick"}, func(ctx context.Context, data websockethandler.WsFuncData) (websockethandler.WsFuncData, error) {
+			clock.Advance(3 * time.Second)
+			return data, nil
+		})
+	if _, err := h.CallFunc(context.Background(), websockethandler.WsFunc{Event: "tick"}, websockethandler.WsFuncData{Payload: websockethandler.MessagePayload{Event: "tick"}}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Duration != 3*time.Second || !entries[0].Time.Equal(time.Unix(0, 0)) {
+		t.Fatalf("access log = %+v, want one entry of 3s at the start of the clock", entries)
+	}
+}
+
+func BenchmarkPipelineOfOneStage(b *testing.B) {
+	h := websockethandler.NewHandler().
+		AddLogger(log.New(io.Discard, "", 0)).
+		Handle(websockethandler.WsFunc{Event: "echo"}, func(ctx context.Context, data websockethandler.WsFuncData) (websockethandler.WsFuncData, error) {
+			return data, nil
+		})
+	BenchmarkPipeline(h, websockethandler.WsFunc{Event: "echo"}, websockethandler.WsFuncData{Payload: websockethandler.MessagePayload{Event: "echo"}}, b)
+}
+
+func BenchmarkCallParallelWithWorkers(b *testing.B) {
+	for _, workers := range []int{0, 1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			var opts []websockethandler.Option
+			if workers > 0 {
+				opts = append(opts, websockethandler.WithWorkers(workers))
+			}
+			h := websockethandler.NewHandler(opts...).
+				AddLogger(log.New(io.Discard, "", 0)).
+				Handle(websockethandler.WsFunc{Event: "work"}, func(ctx context.Context, data websockethandler.WsFuncData) (websockethandler.WsFuncData, error) {
+					time.Sleep(10 * time.Microsecond)
+					return data, nil
+				})
+			BenchmarkCallParallel(h, websockethandler.WsFunc{Event: "work"}, websockethandler.WsFuncData{Payload: websockethandler.MessagePayload{Event: "work"}}, b)
+		})
+	}
+}
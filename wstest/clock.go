@@ -0,0 +1,32 @@
+package wstest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock moved only by Advance, passed to the handler by WithClock(c.Now).
+// The durations measured by the handler then do not depend on the load of the machine
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// Clock starting at start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Current time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Moving the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}